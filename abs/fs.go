@@ -0,0 +1,110 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package abs
+
+import (
+	"errors"
+	"image"
+	_ "image/png" // Add PNG decoder for OpenTexture.
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples"
+)
+
+// overlayFS is a file system that opens files from disk first, and falls back
+// to the embedded assets if they do not exist there.
+type overlayFS struct {
+	disk fs.FS // May be nil.
+}
+
+// Open implements the fs.FS interface.
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if o.disk != nil {
+		f, err := o.disk.Open(name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return examples.Assets.Open(name)
+}
+
+var (
+	fsOnce  sync.Once
+	assetFS overlayFS
+)
+
+// FS returns a file system holding the example asset files, with paths
+// relative to the examples directory. If the examples directory can be found
+// on disk (see Dir) files there override the ones embedded into the binary, so
+// edits to assets take effect without rebuilding.
+func FS() fs.FS {
+	fsOnce.Do(func() {
+		if dir, err := Dir(); err == nil {
+			assetFS.disk = os.DirFS(dir)
+		}
+	})
+	return assetFS
+}
+
+// DiskFS returns a file system of the whole disk holding the file at path,
+// and the name of the file within it. Files such as maps may reference others
+// anywhere relative to them, so the file system is not rooted at the file's
+// directory.
+func DiskFS(path string) (fsys fs.FS, name string, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	root := filepath.VolumeName(path) + string(filepath.Separator)
+	return os.DirFS(root), filepath.ToSlash(path[len(root):]), nil
+}
+
+// OpenShader opens the GLSL shader with the given name from FS, that is the
+// files name+".vert" and name+".frag". The name is also used as the shader's
+// name.
+func OpenShader(name string) (*gfx.Shader, error) {
+	fsys := FS()
+	vert, err := fs.ReadFile(fsys, name+".vert")
+	if err != nil {
+		return nil, err
+	}
+	frag, err := fs.ReadFile(fsys, name+".frag")
+	if err != nil {
+		return nil, err
+	}
+	shader := gfx.NewShader(name)
+	shader.GLSL = &gfx.GLSLSources{
+		Vertex:   vert,
+		Fragment: frag,
+	}
+	return shader, nil
+}
+
+// OpenTexture opens the image file with the given name from FS and returns a
+// texture with it as the source, filtered with the given minification and
+// magnification filters (e.g. gfx.Nearest for pixel art).
+func OpenTexture(name string, min, mag gfx.TexFilter) (*gfx.Texture, error) {
+	f, err := FS().Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	tex := gfx.NewTexture()
+	tex.Source = img
+	tex.Bounds = img.Bounds()
+	tex.MinFilter = min
+	tex.MagFilter = mag
+	return tex, nil
+}
@@ -2,41 +2,193 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package abs finds an absolute path to a example resource file.
+// Package abs finds example resource files, either on disk or embedded into
+// the binary.
 package abs
 
 import (
+	"bytes"
+	"fmt"
 	"go/build"
+	"log"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"sort"
 	"sync"
+	"unicode"
 )
 
+// EnvDir is the name of the environment variable that, when set, explicitly
+// specifies the examples directory and overrides any other search.
+const EnvDir = "AZUL3D_EXAMPLES"
+
+// importPath is the import path (and module path) of the examples.
+const importPath = "azul3d.org/examples"
+
+// NotFoundError is returned when the examples directory could not be found.
+type NotFoundError struct {
+	// Tried is the list of locations that were searched, in order.
+	Tried []string
+}
+
+// Error implements the error interface.
+func (e *NotFoundError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "abs: cannot find the %s directory (set $%s to override), tried:", importPath, EnvDir)
+	for _, t := range e.Tried {
+		fmt.Fprintf(&buf, "\n\t%s", t)
+	}
+	return buf.String()
+}
+
 var (
 	pathLock    sync.Mutex
 	examplesDir string
 )
 
-// Path returns the absolute path to a file given a relative one in the
-// examples directory:
+// Dir returns the absolute path to the examples directory. The first of the
+// following locations that holds the examples is used:
 //
-//  $GOPATH/src/azul3d.org/examples.v1
+//	$AZUL3D_EXAMPLES
+//	$GOMODCACHE/azul3d.org/examples@<version>
+//	$GOPATH/src/azul3d.org/examples
+//
+// If none of them do, a *NotFoundError listing each location is returned.
+func Dir() (string, error) {
+	pathLock.Lock()
+	defer pathLock.Unlock()
+
+	if len(examplesDir) > 0 {
+		return examplesDir, nil
+	}
+	dir, err := search()
+	if err != nil {
+		return "", err
+	}
+	examplesDir = dir
+	return dir, nil
+}
+
+// Find returns the absolute path to a file given a relative one in the
+// examples directory, or an error if the examples directory cannot be found.
+func Find(relPath string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, relPath), nil
+}
+
+// Path returns the absolute path to a file given a relative one in the
+// examples directory (see Dir for how it is found). If the examples directory
+// cannot be found, the error is logged and the program exits.
 //
 // This helper function is not an important concept to any of the examples, it
 // just allows the examples to be ran from any working directory.
 func Path(relPath string) string {
-	pathLock.Lock()
-	defer pathLock.Unlock()
+	p, err := Find(relPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return p
+}
 
-	if len(examplesDir) == 0 {
-		// Find assets directory.
-		for _, path := range filepath.SplitList(build.Default.GOPATH) {
-			path = filepath.Join(path, "src/azul3d.org/examples")
-			if _, err := os.Stat(path); err == nil {
-				examplesDir = path
-				break
+// search searches each possible location of the examples directory in order.
+func search() (string, error) {
+	var tried []string
+	try := func(source, dir string) bool {
+		if len(dir) == 0 {
+			tried = append(tried, source+": not found")
+			return false
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			tried = append(tried, fmt.Sprintf("%s: %v", source, err))
+			return false
+		}
+		if !isExamplesDir(dir) {
+			tried = append(tried, fmt.Sprintf("%s: %s", source, dir))
+			return false
+		}
+		return true
+	}
+
+	// Explicit override.
+	if dir := os.Getenv(EnvDir); len(dir) == 0 {
+		tried = append(tried, "$"+EnvDir+": not set")
+	} else if try("$"+EnvDir, dir) {
+		return filepath.Abs(dir)
+	}
+
+	// Module cache.
+	base, dirs := modCacheDirs()
+	if len(dirs) == 0 {
+		tried = append(tried, "module cache: "+base+"@*")
+	}
+	for _, dir := range dirs {
+		if try("module cache", dir) {
+			return filepath.Abs(dir)
+		}
+	}
+
+	// GOPATH.
+	for _, path := range filepath.SplitList(build.Default.GOPATH) {
+		dir := filepath.Join(path, "src", importPath)
+		if try("GOPATH", dir) {
+			return dir, nil
+		}
+	}
+	return "", &NotFoundError{Tried: tried}
+}
+
+// isExamplesDir reports whether dir looks like the examples directory.
+func isExamplesDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "abs", "path.go"))
+	return err == nil
+}
+
+// modCacheDirs returns the base path of the examples in the module cache and
+// the candidate examples directories under it. The version the binary was
+// built against comes first, followed by every cached version, the lexically
+// greatest first.
+func modCacheDirs() (base string, dirs []string) {
+	cache := os.Getenv("GOMODCACHE")
+	if len(cache) == 0 {
+		gopath := filepath.SplitList(build.Default.GOPATH)
+		if len(gopath) == 0 {
+			return "", nil
+		}
+		cache = filepath.Join(gopath[0], "pkg", "mod")
+	}
+	base = filepath.Join(cache, escapePath(importPath))
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == importPath && info.Main.Version != "(devel)" {
+			dirs = append(dirs, base+"@"+escapePath(info.Main.Version))
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == importPath {
+				dirs = append(dirs, base+"@"+escapePath(dep.Version))
 			}
 		}
 	}
-	return filepath.Join(examplesDir, relPath)
+	matches, _ := filepath.Glob(base + "@*")
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+	return base, append(dirs, matches...)
+}
+
+// escapePath escapes a module path or version the way the module cache does
+// on disk: each upper-case letter becomes an exclamation mark followed by the
+// lower-case letter.
+func escapePath(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			buf.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
 }
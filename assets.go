@@ -0,0 +1,15 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package examples
+
+import "embed"
+
+// Assets holds the shaders, textures, gradients and maps used by the examples,
+// with paths relative to the examples directory (e.g.
+// "azul3d_triangle/triangle.vert"). Embedding them lets a built example run
+// without the examples directory; use the abs package to access them.
+//
+//go:embed azul3d_*/*.vert azul3d_*/*.frag azul3d_*/*.png azul3d_mandel/gradients azul3d_tmx/data
+var Assets embed.FS
@@ -12,12 +12,12 @@ import (
 
 	"azul3d.org/engine/gfx"
 	"azul3d.org/engine/gfx/camera"
-	"azul3d.org/engine/gfx/gfxutil"
 	"azul3d.org/engine/gfx/window"
 	"azul3d.org/engine/keyboard"
 	"azul3d.org/engine/lmath"
 
 	"azul3d.org/examples/abs"
+	"azul3d.org/examples/timing"
 )
 
 // cube returns a cube *gfx.Mesh at an offset from the origin
@@ -70,7 +70,7 @@ func cube(x, y float32) *gfx.Mesh {
 // setup creates the cameras and cubes for both windows
 func setup(d gfx.Device) (camMain, camSecondary *camera.Camera, cubes *gfx.Object) {
 	// Load the shader.
-	shader, err := gfxutil.OpenShader(abs.Path("azul3d_debug_camera/cube"))
+	shader, err := abs.OpenShader("azul3d_debug_camera/cube")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -96,17 +96,17 @@ func setup(d gfx.Device) (camMain, camSecondary *camera.Camera, cubes *gfx.Objec
 	return
 }
 
-func update(d gfx.Device, cubes *gfx.Object, camMain *camera.Camera) {
+func update(d gfx.Device, clk timing.Clock, cubes *gfx.Object, camMain *camera.Camera) {
 	// Clear the entire area.
 	d.Clear(d.Bounds(), gfx.Color{0, 0, 0, 1})
 	d.ClearDepth(d.Bounds(), 1.0)
 
 	// Use frame delta time so the windows stay in sync.
-	dt := d.Clock().Dt()
+	dt := clk.Dt()
 	cubes.SetRot(cubes.Rot().AddScalar(10 * dt))
 
 	// How much we want to add to the FOV and far clipping distance (pulsating).
-	add := math.Sin(float64(d.Clock().FrameCount())/20) * 25
+	add := math.Sin(float64(clk.FrameCount())/20) * 25
 
 	camMain.Near = 1
 	camMain.Far = 30 + add
@@ -135,6 +135,7 @@ var camOrtho = make(chan bool, 1)
 // gfxLoopWindow1 runs the main camera window
 func gfxLoopWindow1(w window.Window, d gfx.Device) {
 	camMain, _, cubes := setup(d)
+	clk := timing.For(d)
 	for {
 		select {
 		case ortho := <-camOrtho:
@@ -143,7 +144,7 @@ func gfxLoopWindow1(w window.Window, d gfx.Device) {
 		}
 
 		// Update the camera position, rotation etc (done in both windows).
-		update(d, cubes, camMain)
+		update(d, clk, cubes, camMain)
 		d.Draw(d.Bounds(), cubes, camMain)
 
 		// Render the whole frame.
@@ -158,6 +159,7 @@ func gfxLoopWindow2(w window.Window, d gfx.Device) {
 	w.Notify(events, window.KeyboardTypedEvents)
 
 	camMain, camSecondary, cubes := setup(d)
+	clk := timing.For(d)
 	for {
 		select {
 		case e := <-events:
@@ -173,7 +175,7 @@ func gfxLoopWindow2(w window.Window, d gfx.Device) {
 		}
 
 		// Update the camera position, rotation etc (done in both windows).
-		update(d, cubes, camMain)
+		update(d, clk, cubes, camMain)
 
 		// Draw the first camera as seen by the secondary camera.
 		d.Draw(d.Bounds(), camMain.Object, camSecondary)
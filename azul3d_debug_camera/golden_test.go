@@ -0,0 +1,36 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/window"
+
+	"azul3d.org/examples/golden"
+	"azul3d.org/examples/headless"
+)
+
+// cubeShader stands in for the cube GLSL shader, coloring the cubes by their
+// vertex positions.
+func cubeShader(f *headless.Fragment) (gfx.Color, bool) {
+	abs := func(v float32) float32 { return float32(math.Abs(float64(v))) / 5 }
+	return gfx.Color{R: abs(f.Vertex.X), G: abs(f.Vertex.Y), B: abs(f.Vertex.Z), A: 1}, false
+}
+
+// TestGolden checks the window of the main camera.
+func TestGolden(t *testing.T) {
+	props := window.NewProps()
+	props.SetSize(640, 400)
+	golden.Run(t, golden.Example{
+		GfxLoop: gfxLoopWindow1,
+		Props:   props,
+		Shaders: map[string]headless.FragmentShader{
+			"azul3d_debug_camera/cube": cubeShader,
+		},
+	})
+}
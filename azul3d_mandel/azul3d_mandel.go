@@ -6,15 +6,18 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"image"
 	"image/color"
 	"image/png"
 	"log"
 	"math"
 	"os"
+	"sync"
+	"time"
 
 	"azul3d.org/engine/gfx"
-	"azul3d.org/engine/gfx/gfxutil"
 	"azul3d.org/engine/gfx/window"
 	"azul3d.org/engine/keyboard"
 	"azul3d.org/engine/mouse"
@@ -22,22 +25,104 @@ import (
 	"azul3d.org/examples/abs"
 )
 
+// Constants for controlling the view.
+const (
+	zoomStep        = 1.1                    // Zoom factor per scroll wheel step.
+	keyZoom         = 2                      // Zoom factor per + or - key press.
+	keyPan          = 0.1                    // Fraction of the window panned per arrow key press.
+	maxHistory      = 100                    // Maximum number of views kept for undo.
+	historyCoalesce = 500 * time.Millisecond // Changes closer together than this are undone at once.
+)
+
+// progressInterval is how often partially generated fields are colored and
+// sent to the texture while the generator is still working on the rest of the
+// image.
+const progressInterval = time.Second / 30
+
+// params are the parameters of a single fractal image.
+type params struct {
+	fractal       Fractal
+	options       Options
+	view          View
+	width, height int
+}
+
 // mandelGen is a mandelbrot texture generator.
 type mandelGen struct {
 	// The channel of generated mandelbrot images. Only the last one received
 	// over this channel is valid.
 	Image chan image.Image
 
-	x, y       float64         // Center, e.g. x=-0.5, y=0.
-	zoom       float64         // Zoom, e.g. 1.0.
-	resolution int             // Resolution divisor, e.g. 8.
-	maxIter    int             // Maximum number of generator iterations, e.g. 1000.
-	needUpdate bool            // Whether or not we should generate an updated image.
-	bounds     image.Rectangle // The framebuffer's bounding rectangle.
+	fractals    []Fractal       // Fractals to choose from.
+	fractal     int             // Index of the fractal in use.
+	juliaC      complex128      // The constant of the Julia set, which follows the cursor.
+	view        View            // The view, e.g. centered at x=-0.5, y=0 with zoom 1.0.
+	home        View            // The view to reset to.
+	undo, redo  []View          // History of views for undo and redo.
+	lastChange  time.Time       // Time the view was last changed.
+	cursor      [2]float64      // Cursor position, in window coordinates.
+	resolution  int             // Resolution divisor, e.g. 8.
+	maxIter     int             // Maximum number of generator iterations, e.g. 1000.
+	mode        Mode            // How orbits are turned into field values.
+	needUpdate  bool            // Whether or not we should generate an updated image.
+	bounds      image.Rectangle // The framebuffer's bounding rectangle.
+	palettes    []Palette       // Palettes to choose from.
+	palette     int             // Index of the palette in use.
+	needRecolor bool            // Whether or not we should recolor the field.
+	field       *Field          // The last (possibly partial) generated field.
+	fields      chan generated  // Generated fields, for coloring.
+	generation  int             // The generation of the field being generated.
+}
+
+// generated is a (possibly partial) field generated for the given generation
+// of the parameters. Fields of an older generation than the current one are
+// stale, and dropped.
+type generated struct {
+	generation int
+	field      *Field
+}
+
+// setView changes the view, remembering the current one for undo. Changes in
+// quick succession (e.g. while dragging) are remembered as one.
+func (m *mandelGen) setView(v View) {
+	if time.Since(m.lastChange) > historyCoalesce {
+		m.undo = append(m.undo, m.view)
+		if len(m.undo) > maxHistory {
+			m.undo = m.undo[1:]
+		}
+	}
+	m.lastChange = time.Now()
+	m.redo = nil
+	m.view = v
+	m.needUpdate = true
+}
+
+// step moves back (undo) or forward (redo) through the history of views, by
+// taking a view from the from list and putting the current one on the to list.
+func (m *mandelGen) step(from, to *[]View) {
+	if len(*from) == 0 {
+		return
+	}
+	*to = append(*to, m.view)
+	m.view = (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	m.lastChange = time.Time{}
+	m.needUpdate = true
 }
 
-// handle is called to handle a window event.
+// handle is called to handle a window event. The controls are:
+//
+//	Left click:   Toggle cursor grab, and pan by moving the mouse while grabbed.
+//	Scroll:       Zoom in or out at the cursor.
+//	Arrow keys:   Pan.
+//	+ and -:      Zoom in or out.
+//	u and r:      Undo or redo changes to the view.
+//	0:            Reset the view.
+//	Right click:  Change the resolution.
+//	f, m and p:   Switch the fractal, mode or palette.
+//	b:            Save a bookmark of the view.
 func (m *mandelGen) handle(e window.Event) {
+	w, h := m.bounds.Dx(), m.bounds.Dy()
 	switch ev := e.(type) {
 	case window.FramebufferResized:
 		m.bounds = image.Rect(0, 0, ev.Width, ev.Height)
@@ -52,77 +137,287 @@ func (m *mandelGen) handle(e window.Event) {
 		}
 
 	case mouse.Scrolled:
-		m.zoom += ev.Y * 0.06 * math.Abs(m.zoom)
-		m.needUpdate = true
+		m.setView(m.view.ZoomAt(math.Pow(zoomStep, ev.Y), m.cursor[0], m.cursor[1], w, h))
+
+	case keyboard.ButtonEvent:
+		if ev.State != keyboard.Down {
+			break
+		}
+		switch ev.Key {
+		case keyboard.ArrowLeft:
+			m.setView(m.view.Pan(-keyPan*float64(w), 0, w, h))
+		case keyboard.ArrowRight:
+			m.setView(m.view.Pan(keyPan*float64(w), 0, w, h))
+		case keyboard.ArrowUp:
+			m.setView(m.view.Pan(0, -keyPan*float64(h), w, h))
+		case keyboard.ArrowDown:
+			m.setView(m.view.Pan(0, keyPan*float64(h), w, h))
+		}
+
+	case keyboard.Typed:
+		switch ev.S {
+		case "p", "P":
+			m.palette = (m.palette + 1) % len(m.palettes)
+			log.Println("Palette:", m.palettes[m.palette].Name())
+			m.needRecolor = true
+
+		case "f", "F":
+			m.fractal = (m.fractal + 1) % len(m.fractals)
+			log.Println("Fractal:", m.fractals[m.fractal].Name())
+			m.needUpdate = true
+
+		case "m", "M":
+			m.mode = (m.mode + 1) % Mode(len(modeNames))
+			log.Println("Mode:", m.mode)
+			m.needUpdate = true
+
+		case "+", "=":
+			m.setView(m.view.ZoomAt(keyZoom, float64(w)/2, float64(h)/2, w, h))
+
+		case "-":
+			m.setView(m.view.ZoomAt(1.0/keyZoom, float64(w)/2, float64(h)/2, w, h))
+
+		case "u", "U":
+			m.step(&m.undo, &m.redo)
+
+		case "r", "R":
+			m.step(&m.redo, &m.undo)
+
+		case "0":
+			m.lastChange = time.Time{}
+			m.setView(m.home)
+
+		case "b", "B":
+			b := newBookmark(m.current(), m.mode, m.view, m.maxIter)
+			if err := saveBookmark(*flagBookmarks, b); err != nil {
+				log.Println(err)
+			} else {
+				log.Println("Saved bookmark to", *flagBookmarks)
+			}
+		}
 
 	case window.CursorMoved:
 		if ev.Delta {
-			m.x += (ev.X / 900.0) / math.Abs(m.zoom)
-			m.y += (ev.Y / 900.0) / math.Abs(m.zoom)
+			// The cursor is grabbed: pan by the distance it moved, and zoom
+			// at the center as the cursor is hidden.
+			m.cursor = [2]float64{float64(w) / 2, float64(h) / 2}
+			m.setView(m.view.Pan(ev.X, ev.Y, w, h))
+			break
+		}
+		m.cursor = [2]float64{ev.X, ev.Y}
+
+		if _, ok := m.fractals[m.fractal].(Julia); ok {
+			// The constant of the Julia set follows the cursor.
+			re, im := m.view.At(ev.X, ev.Y, w, h)
+			cr, _ := re.Float64()
+			ci, _ := im.Float64()
+			m.juliaC = complex(cr, ci)
 			m.needUpdate = true
 		}
 	}
 }
 
-// generate is a small helper function to call Mandelbrot and insert a small
-// red square into the image. It then submits the image over the channel.
-func (m *mandelGen) generate() {
-	width := m.bounds.Dx() / m.resolution
-	height := m.bounds.Dy() / m.resolution
-	mbrot := Mandelbrot(width, height, m.maxIter, m.zoom, m.x, m.y)
+// current returns the fractal in use, with the constant of the Julia set set
+// to juliaC.
+func (m *mandelGen) current() Fractal {
+	if _, ok := m.fractals[m.fractal].(Julia); ok {
+		return Julia{C: m.juliaC}
+	}
+	return m.fractals[m.fractal]
+}
+
+// params returns the parameters of the image that should be generated now.
+func (m *mandelGen) params() params {
+	return params{
+		fractal: m.current(),
+		options: Options{MaxIter: m.maxIter, Mode: m.mode, Interior: true},
+		view:    m.view,
+		width:   m.bounds.Dx() / m.resolution,
+		height:  m.bounds.Dy() / m.resolution,
+	}
+}
+
+// generate calls Generate to generate a field with the given parameters
+// and submits it for coloring, tagged with the generation. If progressive is
+// true the partially generated field is also submitted as tiles complete, such
+// that the image fills in progressively. Generation stops early, without
+// submitting anything more, if ctx is canceled.
+func (m *mandelGen) generate(ctx context.Context, generation int, p params, progressive bool) {
+	var (
+		lock  sync.Mutex
+		dirty bool
+		field = NewField(image.Rect(0, 0, p.width, p.height), p.options.MaxIter)
+		done  = make(chan error, 1)
+	)
+	go func() {
+		done <- Generate(ctx, p.fractal, p.options, p.view, p.width, p.height, func(tile *Field) {
+			lock.Lock()
+			field.Draw(tile)
+			dirty = true
+			lock.Unlock()
+		})
+	}()
+
+	var progress <-chan time.Time
+	if progressive {
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+		progress = t.C
+	}
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				m.send(ctx, generated{generation, field})
+			}
+			return
+
+		case <-progress:
+			lock.Lock()
+			var partial *Field
+			if dirty {
+				dirty = false
+				partial = field.Copy()
+			}
+			lock.Unlock()
+			if partial != nil {
+				m.send(ctx, generated{generation, partial})
+			}
+		}
+	}
+}
+
+// send submits the field for coloring, unless ctx is canceled first. A field
+// may still be received after ctx is canceled, when both are ready at once, so
+// run also drops fields of older generations.
+func (m *mandelGen) send(ctx context.Context, g generated) {
+	if ctx.Err() != nil {
+		return
+	}
+	select {
+	case m.fields <- g:
+	case <-ctx.Done():
+	}
+}
+
+// colorize colors the last generated field using the current palette and
+// submits the image over the channel.
+func (m *mandelGen) colorize() {
+	img := m.palettes[m.palette].Colorize(m.field)
 
 	// Insert a small red square in the top-left of the image for ensuring
 	// proper orientation exists in textures (this is just for testing).
 	for x := 0; x < 5; x++ {
 		for y := 0; y < 5; y++ {
-			mbrot.Set(x, y, color.RGBA{255, 0, 0, 255})
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
 		}
 	}
-	m.Image <- mbrot
+	m.Image <- img
 }
 
 // run is the mandelbrot generation goroutine. It listens for window events and
-// responds by generating new mandelbrot images if needed.
+// responds by generating new mandelbrot images if needed, or by recoloring the
+// last one. Any field that is still being generated when the next one is
+// needed is abandoned.
 func (m *mandelGen) run(w window.Window) {
-	// generate the initial mandelbrot image right now.
-	m.generate()
-
 	// Have the window notify us of specific events.
 	evMask := window.MouseEvents
 	evMask |= window.MouseScrolledEvents
 	evMask |= window.FramebufferResizedEvents
 	evMask |= window.CursorMovedEvents
+	evMask |= window.KeyboardTypedEvents
+	evMask |= window.KeyboardButtonEvents
 	event := make(chan window.Event, 256)
 	w.Notify(event, evMask)
 
-	// Wait for window events.
-	for {
-		// Wait for one window event now.
-		m.handle(<-event)
+	// generate the initial mandelbrot field right now, without partial
+	// images.
+	ctx, cancel := context.WithCancel(context.Background())
+	go m.generate(ctx, m.generation, m.params(), false)
 
-		// Handle as many window events as possible now. We do this because
-		// generating mandelbrot images on the CPU is expensive, so we only
-		// want to do it for the very last event.
-		window.Poll(event, m.handle)
+	for {
+		select {
+		case e := <-event:
+			// Handle as many window events as possible now. We do this
+			// because generating mandelbrot images on the CPU is expensive,
+			// so we only want to do it for the very last event.
+			m.handle(e)
+			window.Poll(event, m.handle)
+
+		case g := <-m.fields:
+			if g.generation == m.generation {
+				m.field = g.field
+				m.needRecolor = true
+			}
+		}
 
-		// If we need to update, generate the next mandelbrot image.
+		// If we need to update, cancel the stale field and start generating
+		// the next one.
 		if m.needUpdate {
 			m.needUpdate = false
-			m.generate()
+			cancel()
+			ctx, cancel = context.WithCancel(context.Background())
+			m.generation++
+			go m.generate(ctx, m.generation, m.params(), true)
+		}
+
+		// Recolor the field if it has changed or the palette has.
+		if m.needRecolor && m.field != nil {
+			m.needRecolor = false
+			m.colorize()
 		}
 	}
 }
 
-// newMandelGen returns a new mandelbrot generator.
+// newMandelGen returns a new mandelbrot generator, starting with the view
+// specified by the command-line flags. The palettes are loaded from the
+// gradient files in the gradients directory.
 func newMandelGen(w window.Window, d gfx.Device) *mandelGen {
+	view, err := parseView(*flagCenter, *flagZoom)
+	if err != nil {
+		log.Fatal(err)
+	}
+	palettes, err := LoadPalettes(abs.FS(), "azul3d_mandel/gradients")
+	if err != nil {
+		log.Fatal(err)
+	}
+	palette, err := findPalette(palettes, *flagPalette)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fractals := Fractals()
+	fractal, err := findFractal(fractals, *flagFractal)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mode, err := parseMode(*flagMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The constant of the Julia set starts at that of the Julia fractal.
+	var juliaC complex128
+	for _, f := range fractals {
+		if j, ok := f.(Julia); ok {
+			juliaC = j.C
+		}
+	}
+
 	m := &mandelGen{
 		Image:      make(chan image.Image),
-		x:          -.5,
-		y:          0,
-		zoom:       1,
+		fractals:   fractals,
+		fractal:    fractal,
+		juliaC:     juliaC,
+		view:       view,
+		home:       view,
 		resolution: 8,
-		maxIter:    1000,
+		maxIter:    *flagIter,
+		mode:       mode,
 		bounds:     d.Bounds(),
+		cursor:     [2]float64{float64(d.Bounds().Dx()) / 2, float64(d.Bounds().Dy()) / 2},
+		palettes:   palettes,
+		palette:    palette,
+		fields:     make(chan generated),
 	}
 
 	// Spawn the mandelbrot generation goroutine.
@@ -135,8 +430,8 @@ func gfxLoop(w window.Window, d gfx.Device) {
 	// Create a new mandelbrot generator.
 	gen := newMandelGen(w, d)
 
-	// Read the GLSL shaders from disk.
-	shader, err := gfxutil.OpenShader(abs.Path("azul3d_mandel/mandel"))
+	// Read the GLSL shaders.
+	shader, err := abs.OpenShader("azul3d_mandel/mandel")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -259,5 +554,22 @@ func gfxLoop(w window.Window, d gfx.Device) {
 }
 
 func main() {
+	flag.Parse()
+
+	// Render the view or a flight straight to files without a window, if
+	// requested.
+	if len(*flagRender) > 0 {
+		if err := renderFile(*flagRender); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(*flagFlight) > 0 {
+		if err := renderFlight(*flagFlight); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	window.Run(gfxLoop, nil)
 }
@@ -0,0 +1,142 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/big"
+	"math/cmplx"
+)
+
+// deepZoom is the zoom beyond which float64 coordinates are no longer precise
+// enough to tell neighboring pixels apart, and perturbation is used instead.
+//
+// Perturbation keeps the per-pixel math in float64, which works up to zooms of
+// about 1e300.
+const deepZoom = 1e10
+
+// seriesTolerance is the largest relative error allowed in the series
+// approximation before it stops being used to skip iterations.
+const seriesTolerance = 1e-9
+
+// precision returns the number of mantissa bits that coordinates need at the
+// given zoom.
+func precision(zoom float64) uint {
+	return 64 + uint(math.Max(0, math.Log2(math.Abs(zoom))))
+}
+
+// reference is a reference orbit calculated with arbitrary precision at the
+// center of the image. The orbits of the pixels are calculated as float64
+// deltas to it (i.e. by perturbation), which stay small enough to be precise.
+type reference struct {
+	maxIter int
+
+	// orbit holds Z[n] of the reference point, from Z[0] = 0 until it escaped
+	// or reached the maximum number of iterations.
+	orbit []complex128
+
+	// Series approximation of the first skip iterations: for a pixel with a
+	// delta dc, the delta of its orbit after skip iterations is
+	// a*dc + b*dc^2 + c*dc^3.
+	skip    int
+	a, b, c complex128
+}
+
+// newReference calculates the reference orbit of the point x, y (with the
+// precision of x) and the series approximation for deltas up to maxDelta in
+// size.
+func newReference(x, y *big.Float, maxIter int, maxDelta float64) *reference {
+	r := &reference{maxIter: maxIter}
+	r.calcOrbit(x, y)
+	r.calcSeries(maxDelta)
+	return r
+}
+
+// calcOrbit calculates the reference orbit of the point x, y.
+func (r *reference) calcOrbit(x, y *big.Float) {
+	prec := x.Prec()
+	newFloat := func() *big.Float {
+		return new(big.Float).SetPrec(prec)
+	}
+	var (
+		zr, zi     = newFloat(), newFloat()
+		zr2, zi2   = newFloat(), newFloat()
+		tmp        = newFloat()
+		two        = newFloat().SetInt64(2)
+		fr, fi     float64
+		orbit      = []complex128{0}
+		escapedSqr = 4.0
+	)
+	for i := 0; i < r.maxIter; i++ {
+		// Z = Z^2 + C
+		zr2.Mul(zr, zr)
+		zi2.Mul(zi, zi)
+		tmp.Mul(zr, zi)
+		zi.Mul(tmp, two)
+		zi.Add(zi, y)
+		zr.Sub(zr2, zi2)
+		zr.Add(zr, x)
+
+		fr, _ = zr.Float64()
+		fi, _ = zi.Float64()
+		orbit = append(orbit, complex(fr, fi))
+		if fr*fr+fi*fi > escapedSqr {
+			break
+		}
+	}
+	r.orbit = orbit
+}
+
+// calcSeries determines how many iterations the series approximation can skip
+// for deltas up to maxDelta in size, and its coefficients.
+func (r *reference) calcSeries(maxDelta float64) {
+	// The skipped iterations must leave at least one step of the reference
+	// orbit to continue from.
+	var a, b, c complex128
+	for n := 0; n < len(r.orbit)-2; n++ {
+		z := r.orbit[n]
+		na := 2*z*a + 1
+		nb := 2*z*b + a*a
+		nc := 2*z*c + 2*a*b
+
+		// Stop once the cubic term is no longer negligible compared to the
+		// linear one.
+		if cmplx.Abs(nc)*maxDelta*maxDelta > seriesTolerance*cmplx.Abs(na) {
+			break
+		}
+		a, b, c = na, nb, nc
+		r.skip = n + 1
+	}
+	r.a, r.b, r.c = a, b, c
+}
+
+// escape returns the field value of the pixel whose point is dc away from the
+// reference point.
+func (r *reference) escape(dc complex128) float64 {
+	// Start with the series approximation, skipping the first iterations.
+	dz := r.a*dc + r.b*dc*dc + r.c*dc*dc*dc
+	n := r.skip
+	for i := r.skip; i < r.maxIter; i++ {
+		// z = Z + dz, so z^2 + c = Z^2 + C + 2*Z*dz + dz^2 + dc.
+		dz = 2*r.orbit[n]*dz + dz*dz + dc
+		n++
+
+		z := r.orbit[n] + dz
+		zSqr := real(z)*real(z) + imag(z)*imag(z)
+		if zSqr > 4 {
+			return float64(i) - math.Log2(math.Log2(math.Sqrt(zSqr)))
+		}
+
+		// Rebase onto the start of the reference orbit when the pixel's orbit
+		// gets closer to zero than to the reference orbit (where the delta
+		// would lose precision), or when the reference orbit ends.
+		dzSqr := real(dz)*real(dz) + imag(dz)*imag(dz)
+		if zSqr < dzSqr || n == len(r.orbit)-1 {
+			dz = z
+			n = 0
+		}
+	}
+	return Inside
+}
@@ -0,0 +1,96 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// directEscape returns the escape-time value of the point re, im by direct
+// iteration with 256 bits of precision, which float64 iteration falls short of
+// for orbits that stay close to the boundary of the set for long.
+func directEscape(re, im *big.Float, maxIter int) float64 {
+	newFloat := func() *big.Float { return new(big.Float).SetPrec(256) }
+	var (
+		cr, ci   = newFloat().Set(re), newFloat().Set(im)
+		zr, zi   = newFloat(), newFloat()
+		zr2, zi2 = newFloat(), newFloat()
+		tmp      = newFloat()
+	)
+	for i := 0; i < maxIter; i++ {
+		// Z = Z^2 + C
+		zr2.Mul(zr, zr)
+		zi2.Mul(zi, zi)
+		tmp.Mul(zr, zi)
+		zi.Add(tmp, tmp)
+		zi.Add(zi, ci)
+		zr.Sub(zr2, zi2)
+		zr.Add(zr, cr)
+
+		fr, _ := zr.Float64()
+		fi, _ := zi.Float64()
+		if zSqr := fr*fr + fi*fi; zSqr > 4 {
+			return float64(i) - math.Log2(math.Log2(math.Sqrt(zSqr)))
+		}
+	}
+	return Inside
+}
+
+// TestPerturbation checks the values of perturbation against a reference
+// orbit, with the series approximation and rebasing, against those of direct
+// iteration at moderate zooms.
+func TestPerturbation(t *testing.T) {
+	const w, h, maxIter = 64, 36, 1000
+
+	// Orbits escaping late are chaotic enough for the rounding errors of the
+	// float64 deltas to change their values.
+	late := func(v float64) bool { return v > maxIter/2 && v != Inside }
+	rebased := false
+	for _, center := range []string{
+		"0.2501,0", // The reference escapes, while most pixels are inside.
+		"-0.75,0.1",
+		"-1.25,0.02",
+		"-1.7499,0.00001",
+		"-0.743643887037158704752191506114774,0.131825904205311970493132056385139",
+	} {
+		for _, zoom := range []float64{1e4, 1e6, 1e8} {
+			v := mustParseView(t, center, zoom)
+			dr, di := v.Delta(0, 0, w, h)
+			ref := newReference(v.X, v.Y, maxIter, math.Hypot(dr, di))
+			if ref.skip == 0 {
+				t.Errorf("%s zoom %v: the series approximation skips no iterations", center, zoom)
+			}
+
+			inside := 0
+			for y := 0; y < h; y += 3 {
+				for x := 0; x < w; x += 3 {
+					dr, di := v.Delta(float64(x), float64(y), w, h)
+					got := ref.escape(complex(dr, di))
+					re, im := v.At(float64(x), float64(y), w, h)
+					want := directEscape(re, im, maxIter)
+					if want == Inside {
+						inside++
+					}
+					if late(got) || late(want) {
+						continue
+					}
+					if math.Abs(got-want) > 1e-4 {
+						t.Errorf("%s zoom %v: pixel %d,%d is %v by perturbation, %v by direct iteration", center, zoom, x, y, got, want)
+					}
+				}
+			}
+
+			// Pixels that outlive the reference orbit are rebased onto it.
+			if inside > 0 && len(ref.orbit) <= maxIter {
+				rebased = true
+			}
+		}
+	}
+	if !rebased {
+		t.Error("no pixels outlived the reference orbit")
+	}
+}
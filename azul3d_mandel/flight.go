@@ -0,0 +1,215 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Flags for saving bookmarks and rendering flights through them.
+var (
+	flagBookmarks = flag.String("bookmarks", "bookmarks.json", "file that the b key saves bookmarks of the view to")
+	flagFlight    = flag.String("flight", "", "render a zoom flight through the bookmarks in this file to a PNG sequence and exit")
+	flagFrames    = flag.Int("frames", 120, "number of frames of -flight from each bookmark to the next")
+	flagOut       = flag.String("out", "frames", "directory that -flight writes the PNG sequence to")
+)
+
+// Bookmark is a saved view. The center is stored as decimal text so that it
+// keeps its full precision.
+type Bookmark struct {
+	Fractal string      `json:"fractal"`
+	Mode    string      `json:"mode,omitempty"` // Defaults to "escape".
+	X       string      `json:"x"`
+	Y       string      `json:"y"`
+	Zoom    float64     `json:"zoom"`
+	MaxIter int         `json:"maxIter"`
+	JuliaC  *[2]float64 `json:"juliaC,omitempty"` // Constant of the Julia set.
+}
+
+// newBookmark returns a bookmark of the given view.
+func newBookmark(f Fractal, mode Mode, v View, maxIter int) Bookmark {
+	b := Bookmark{
+		Fractal: f.Name(),
+		Mode:    mode.String(),
+		X:       v.X.Text('g', -1),
+		Y:       v.Y.Text('g', -1),
+		Zoom:    v.Zoom,
+		MaxIter: maxIter,
+	}
+	if j, ok := f.(Julia); ok {
+		b.JuliaC = &[2]float64{real(j.C), imag(j.C)}
+	}
+	return b
+}
+
+// view returns the view of the bookmark.
+func (b Bookmark) view() (f Fractal, mode Mode, v View, err error) {
+	fractals := Fractals()
+	i, err := findFractal(fractals, b.Fractal)
+	if err != nil {
+		return nil, 0, v, err
+	}
+	f = fractals[i]
+	if b.JuliaC != nil {
+		f = Julia{C: complex(b.JuliaC[0], b.JuliaC[1])}
+	}
+	if len(b.Mode) > 0 {
+		if mode, err = parseMode(b.Mode); err != nil {
+			return nil, 0, v, err
+		}
+	}
+	v, err = parseView(b.X+","+b.Y, b.Zoom)
+	return f, mode, v, err
+}
+
+// loadBookmarks loads the bookmarks in the JSON file at path.
+func loadBookmarks(path string) ([]Bookmark, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var bookmarks []Bookmark
+	if err := json.Unmarshal(data, &bookmarks); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return bookmarks, nil
+}
+
+// saveBookmark appends the bookmark to the JSON file at path, creating it if
+// it does not exist.
+func saveBookmark(path string, b Bookmark) error {
+	bookmarks, err := loadBookmarks(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	data, err := json.MarshalIndent(append(bookmarks, b), "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// renderFlight renders a flight through the bookmarks in the JSON file at
+// path, as a numbered PNG sequence in the output directory. Frames that exist
+// already are skipped, so an interrupted render resumes where it left off.
+//
+// Between two bookmarks the zoom changes exponentially, such that the flight
+// appears to move at a constant speed, and the center moves along with it so
+// that the first bookmark's view zooms straight into the second's.
+func renderFlight(path string) error {
+	bookmarks, err := loadBookmarks(path)
+	if err != nil {
+		return err
+	}
+	if len(bookmarks) < 2 {
+		return fmt.Errorf("%s: a flight needs at least two bookmarks", path)
+	}
+	width, height, err := parseSize(*flagSize)
+	if err != nil {
+		return err
+	}
+	palettes, palette, err := loadPalette(*flagPalette)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*flagOut, 0755); err != nil {
+		return err
+	}
+
+	frames := *flagFrames
+	if frames < 1 {
+		return fmt.Errorf("invalid number of frames %d", frames)
+	}
+	total := (len(bookmarks)-1)*frames + 1
+	for frame := 0; frame < total; frame++ {
+		name := filepath.Join(*flagOut, fmt.Sprintf("frame%05d.png", frame))
+		if complete(name) {
+			continue
+		}
+
+		// Find the bookmarks the frame lies between.
+		seg := frame / frames
+		t := float64(frame%frames) / float64(frames)
+		if seg == len(bookmarks)-1 {
+			seg, t = seg-1, 1
+		}
+		f, o, v, err := interpolate(bookmarks[seg], bookmarks[seg+1], t)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		img, err := renderImage(f, o, v, palettes[palette], width, height, *flagSS)
+		if err != nil {
+			return err
+		}
+		// Write to a temporary file first, such that an interrupted render
+		// never leaves a partial frame behind.
+		if err := writePNG(name+".tmp", img); err != nil {
+			return err
+		}
+		if err := os.Rename(name+".tmp", name); err != nil {
+			return err
+		}
+		log.Printf("Frame %d/%d (zoom %.3g) rendered in %v\n", frame+1, total, v.Zoom, time.Since(start))
+	}
+	return nil
+}
+
+// complete reports whether the frame at path was rendered already.
+func complete(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = png.DecodeConfig(f)
+	return err == nil
+}
+
+// interpolate returns the view at t (from 0 to 1) of the way from bookmark a
+// to b. The fractal and mode of a are used throughout.
+func interpolate(a, b Bookmark, t float64) (f Fractal, o Options, v View, err error) {
+	f, mode, av, err := a.view()
+	if err != nil {
+		return nil, o, v, err
+	}
+	_, _, bv, err := b.view()
+	if err != nil {
+		return nil, o, v, err
+	}
+
+	// Exponential zoom, i.e. linear in log space.
+	zoom := a.Zoom * math.Pow(b.Zoom/a.Zoom, t)
+	o = Options{
+		MaxIter:  int(float64(a.MaxIter) + t*float64(b.MaxIter-a.MaxIter) + 0.5),
+		Mode:     mode,
+		Interior: true,
+	}
+
+	// The fraction of the way the center has moved: proportional to the
+	// change in view size, or linear when the zoom stays the same.
+	w := t
+	if r := a.Zoom / b.Zoom; math.Abs(r-1) > 1e-9 {
+		w = (1 - a.Zoom/zoom) / (1 - r)
+	}
+	prec := precision(math.Max(math.Abs(a.Zoom), math.Abs(b.Zoom)))
+	lerp := func(a, b *big.Float) *big.Float {
+		d := new(big.Float).SetPrec(prec).Sub(b, a)
+		d.Mul(d, big.NewFloat(w))
+		return d.Add(d, a)
+	}
+	return f, o, View{X: lerp(av.X, bv.X), Y: lerp(av.Y, bv.Y), Zoom: zoom}, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBookmarks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bookmarks.json")
+	tests := []struct {
+		f       Fractal
+		mode    Mode
+		v       View
+		maxIter int
+	}{
+		{Mandelbrot{}, EscapeTime, mustParseView(t, "-0.5,0", 1), 1000},
+		{Mandelbrot{}, DistanceEstimate, mustParseView(t, viewTests[2].center, viewTests[2].zoom), 5000},
+		{Julia{C: complex(-0.8, 0.156)}, EscapeTime, mustParseView(t, "0.1,-0.2", 4), 300},
+	}
+	for _, tst := range tests {
+		if err := saveBookmark(path, newBookmark(tst.f, tst.mode, tst.v, tst.maxIter)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Bookmarks are appended, and load as the views they were saved from
+	// with their full precision.
+	bookmarks, err := loadBookmarks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != len(tests) {
+		t.Fatalf("loaded %d bookmarks, want %d", len(bookmarks), len(tests))
+	}
+	for i, tst := range tests {
+		b := bookmarks[i]
+		f, mode, v, err := b.view()
+		if err != nil {
+			t.Errorf("bookmark %d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(f, tst.f) || mode != tst.mode || !sameView(v, tst.v) || b.MaxIter != tst.maxIter {
+			t.Errorf("bookmark %d is %s %v at %v,%v zoom %v (%d iterations), want %s %v at %v,%v zoom %v (%d iterations)",
+				i, f.Name(), mode, v.X, v.Y, v.Zoom, b.MaxIter, tst.f.Name(), tst.mode, tst.v.X, tst.v.Y, tst.v.Zoom, tst.maxIter)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	a := newBookmark(Mandelbrot{}, DistanceEstimate, mustParseView(t, "-0.5,0", 1), 100)
+	b := newBookmark(Mandelbrot{}, EscapeTime, mustParseView(t, "-0.743643887037158704752191506114774,0.131825904205311970493132056385139", 1e12), 2100)
+	_, _, av, _ := a.view()
+	_, _, bv, _ := b.view()
+
+	// The flight starts and ends at the views of the bookmarks.
+	for _, end := range []struct {
+		t       float64
+		v       View
+		maxIter int
+	}{{0, av, 100}, {1, bv, 2100}} {
+		f, o, v, err := interpolate(a, b, end.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The centers are within a millionth of a pixel of a 1000 pixels
+		// wide image.
+		eps := big.NewFloat(v.PixelSize(1000) * 1e-6)
+		dx := new(big.Float).Sub(v.X, end.v.X)
+		dy := new(big.Float).Sub(v.Y, end.v.Y)
+		if dx.Abs(dx).Cmp(eps) > 0 || dy.Abs(dy).Cmp(eps) > 0 || !near(v.Zoom, end.v.Zoom) {
+			t.Errorf("at %v: view %v,%v zoom %v, want %v,%v zoom %v", end.t, v.X, v.Y, v.Zoom, end.v.X, end.v.Y, end.v.Zoom)
+		}
+		if o.MaxIter != end.maxIter {
+			t.Errorf("at %v: %d iterations, want %d", end.t, o.MaxIter, end.maxIter)
+		}
+		// The fractal and mode of the first bookmark are used throughout.
+		if f != (Mandelbrot{}) || o.Mode != DistanceEstimate {
+			t.Errorf("at %v: %s %v, want %s %v", end.t, f.Name(), o.Mode, Mandelbrot{}.Name(), DistanceEstimate)
+		}
+	}
+
+	// The zoom grows by the same factor each frame.
+	const frames = 24
+	var prev View
+	for i := 0; i <= frames; i++ {
+		_, _, v, err := interpolate(a, b, float64(i)/frames)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 {
+			if r := v.Zoom / prev.Zoom; !near(r, math.Pow(1e12, 1.0/frames)) {
+				t.Errorf("frame %d: zoomed in by %v, want %v", i, r, math.Pow(1e12, 1.0/frames))
+			}
+		}
+		prev = v
+	}
+}
+
+// TestRenderFlightResume checks that an interrupted flight render resumes
+// where it left off, rendering the missing and partial frames only.
+func TestRenderFlightResume(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "frames")
+	path := filepath.Join(dir, "bookmarks.json")
+	for _, b := range []Bookmark{
+		newBookmark(Mandelbrot{}, EscapeTime, mustParseView(t, "-0.5,0", 1), 50),
+		newBookmark(Mandelbrot{}, EscapeTime, mustParseView(t, "-0.75,0.1", 100), 50),
+	} {
+		if err := saveBookmark(path, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flags := []*string{flagSize, flagOut, flagPalette}
+	saved := []string{*flagSize, *flagOut, *flagPalette}
+	savedFrames, savedSS := *flagFrames, *flagSS
+	defer func() {
+		for i, f := range flags {
+			*f = saved[i]
+		}
+		*flagFrames, *flagSS = savedFrames, savedSS
+	}()
+	*flagSize, *flagOut, *flagPalette = "16x9", out, "classic"
+	*flagFrames, *flagSS = 2, 1
+
+	if err := renderFlight(path); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"frame00000.png", "frame00001.png", "frame00002.png"}
+	rendered := make(map[string][]byte)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rendered[name] = data
+	}
+
+	// Interrupt the render: the last frame is missing, the one before was
+	// cut short while being written, and the first is complete (marked such
+	// that rendering it again would be noticed).
+	marker := append(append([]byte{}, rendered[names[0]]...), "marker"...)
+	if err := os.WriteFile(filepath.Join(out, names[0]), marker, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, names[1]+".tmp"), rendered[names[1]][:20], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, names[1]), rendered[names[1]][:20], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(out, names[2])); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := renderFlight(path); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("frames directory holds %v, want %v", got, names)
+	}
+	for i, name := range names {
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := rendered[name]
+		if i == 0 {
+			want = marker
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("%s differs after resuming", name)
+		}
+	}
+}
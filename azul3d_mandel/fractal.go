@@ -0,0 +1,260 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// Fractal is an escape-time fractal that the generator can calculate.
+type Fractal interface {
+	// Name returns the name of the fractal, e.g. "mandelbrot".
+	Name() string
+
+	// Escape returns the field value of the point c, as specified by the
+	// mode of the options, or Inside if its orbit did not escape within the
+	// maximum number of iterations.
+	//
+	// For fractals whose orbits converge to one of several attractors instead
+	// of escaping, the value is the number of iterations it took to converge
+	// regardless of the mode, and basin is the attractor it converged to
+	// (counting from 1). Otherwise basin is zero.
+	Escape(c complex128, o Options) (v float64, basin int)
+}
+
+// Fractals returns each fractal to choose from, in the order they are switched
+// between.
+func Fractals() []Fractal {
+	return []Fractal{
+		Mandelbrot{},
+		Julia{C: complex(-0.8, 0.156)},
+		BurningShip{},
+		Tricorn{},
+		Multibrot{Power: 3},
+		Newton{},
+	}
+}
+
+// findFractal returns the index of the fractal with the given name.
+func findFractal(fractals []Fractal, name string) (int, error) {
+	for i, f := range fractals {
+		if f.Name() == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown fractal %q", name)
+}
+
+// smooth returns the smooth iteration count of an orbit that escaped at the
+// given iteration with z, for fractals of the given power.
+func smooth(i int, z complex128, power float64) float64 {
+	return float64(i) - math.Log(math.Log2(cmplx.Abs(z)))/math.Log(power)
+}
+
+// Mandelbrot is the Mandelbrot set: z = z^2 + c, starting at z = 0.
+//
+// Its interior detection skips points in the main cardioid and the period-2
+// bulb, and stops iterating once an orbit is found to be periodic.
+type Mandelbrot struct{}
+
+// mandelbrot is the iteration of the Mandelbrot set.
+var mandelbrot = polynomial{
+	step:  func(z, c complex128) complex128 { return z*z + c },
+	deriv: func(z, dz complex128) complex128 { return 2*z*dz + 1 },
+	power: 2,
+}
+
+// periodTolerance is how close an orbit must come to a previous point of it
+// to be considered periodic.
+const periodTolerance = 1e-13
+
+// Name implements the Fractal interface.
+func (Mandelbrot) Name() string { return "mandelbrot" }
+
+// Escape implements the Fractal interface.
+func (Mandelbrot) Escape(c complex128, o Options) (float64, int) {
+	// Orbit traps need the orbits of interior points, too.
+	if o.Interior && o.Mode != OrbitTrap && inMainBulbs(c) {
+		return Inside, 0
+	}
+	if o.Mode != EscapeTime {
+		return mandelbrot.orbit(0, 0, c, o), 0
+	}
+
+	var (
+		pr, pi                     = real(c), imag(c)
+		newRe, newIm, oldRe, oldIm float64
+		checkRe, checkIm           float64
+		checkAt                    = 1
+		i                          int
+	)
+	for i = 0; i < o.MaxIter; i++ {
+		oldRe = newRe
+		oldIm = newIm
+		newRe = oldRe*oldRe - oldIm*oldIm + pr
+		newIm = 2*oldRe*oldIm + pi
+		if (newRe*newRe + newIm*newIm) > 4 {
+			break
+		}
+
+		if o.Interior {
+			// Compare against a point of the orbit that is saved at each
+			// power of two iterations, which finds cycles of any length.
+			if math.Abs(newRe-checkRe) < periodTolerance && math.Abs(newIm-checkIm) < periodTolerance {
+				return Inside, 0
+			}
+			if i == checkAt {
+				checkRe, checkIm = newRe, newIm
+				checkAt *= 2
+			}
+		}
+	}
+
+	if i == o.MaxIter {
+		return Inside, 0
+	}
+	z := math.Sqrt(newRe*newRe + newIm*newIm)
+	return float64(i) - math.Log2(math.Log2(z)), 0
+}
+
+// inMainBulbs reports whether c is inside the main cardioid or the period-2
+// bulb of the Mandelbrot set.
+func inMainBulbs(c complex128) bool {
+	x, y := real(c), imag(c)
+	q := (x-0.25)*(x-0.25) + y*y
+	if q*(q+(x-0.25)) <= 0.25*y*y {
+		return true
+	}
+	return (x+1)*(x+1)+y*y <= 1.0/16
+}
+
+// Julia is the Julia set of the constant C: z = z^2 + C, starting at z = c.
+type Julia struct {
+	C complex128
+}
+
+// Name implements the Fractal interface.
+func (Julia) Name() string { return "julia" }
+
+// Escape implements the Fractal interface.
+func (j Julia) Escape(c complex128, o Options) (float64, int) {
+	p := polynomial{
+		step:  func(z, _ complex128) complex128 { return z*z + j.C },
+		deriv: func(z, dz complex128) complex128 { return 2 * z * dz },
+		power: 2,
+	}
+	return p.orbit(c, 1, c, o), 0
+}
+
+// BurningShip is the Burning Ship fractal: z = (|Re(z)| + |Im(z)|i)^2 + c.
+type BurningShip struct{}
+
+// burningShip is the iteration of the Burning Ship fractal.
+var burningShip = polynomial{
+	step: func(z, c complex128) complex128 {
+		z = complex(math.Abs(real(z)), math.Abs(imag(z)))
+		return z*z + c
+	},
+	power: 2,
+}
+
+// Name implements the Fractal interface.
+func (BurningShip) Name() string { return "burningship" }
+
+// Escape implements the Fractal interface.
+func (BurningShip) Escape(c complex128, o Options) (float64, int) {
+	return burningShip.orbit(0, 0, c, o), 0
+}
+
+// Tricorn is the Tricorn (or Mandelbar) fractal: z = conj(z)^2 + c.
+type Tricorn struct{}
+
+// tricorn is the iteration of the Tricorn fractal.
+var tricorn = polynomial{
+	step: func(z, c complex128) complex128 {
+		z = cmplx.Conj(z)
+		return z*z + c
+	},
+	power: 2,
+}
+
+// Name implements the Fractal interface.
+func (Tricorn) Name() string { return "tricorn" }
+
+// Escape implements the Fractal interface.
+func (Tricorn) Escape(c complex128, o Options) (float64, int) {
+	return tricorn.orbit(0, 0, c, o), 0
+}
+
+// Multibrot is the generalization of the Mandelbrot set to z = z^Power + c.
+type Multibrot struct {
+	Power int
+}
+
+// Name implements the Fractal interface.
+func (m Multibrot) Name() string { return fmt.Sprintf("multibrot%d", m.Power) }
+
+// Escape implements the Fractal interface.
+func (m Multibrot) Escape(c complex128, o Options) (float64, int) {
+	// pow returns z^n for small n.
+	pow := func(z complex128, n int) complex128 {
+		zn := complex(1, 0)
+		for i := 0; i < n; i++ {
+			zn *= z
+		}
+		return zn
+	}
+	p := polynomial{
+		step: func(z, c complex128) complex128 { return pow(z, m.Power) + c },
+		deriv: func(z, dz complex128) complex128 {
+			return complex(float64(m.Power), 0)*pow(z, m.Power-1)*dz + 1
+		},
+		power: float64(m.Power),
+	}
+	return p.orbit(0, 0, c, o), 0
+}
+
+// newtonRoots are the roots of z^3 - 1, the attractors of Newton.
+var newtonRoots = [3]complex128{
+	1,
+	complex(-0.5, math.Sqrt(3)/2),
+	complex(-0.5, -math.Sqrt(3)/2),
+}
+
+// newtonTolerance is how close to a root an orbit must get to have converged.
+const newtonTolerance = 1e-6
+
+// Newton is the Newton fractal of z^3 - 1: Newton's method, z = z - (z^3 -
+// 1) / 3z^2 starting at z = c, colored by the root it converges to.
+type Newton struct{}
+
+// Name implements the Fractal interface.
+func (Newton) Name() string { return "newton" }
+
+// Escape implements the Fractal interface.
+func (Newton) Escape(c complex128, o Options) (float64, int) {
+	z := c
+	for i := 0; i < o.MaxIter; i++ {
+		z2 := z * z
+		if z2 == 0 {
+			break
+		}
+		z -= (z2*z - 1) / (3 * z2)
+		for r, root := range newtonRoots {
+			if d := cmplx.Abs(z - root); d < newtonTolerance {
+				// Smooth the count by how far past the tolerance the last
+				// step went.
+				v := float64(i)
+				if d > 0 {
+					v -= math.Log2(math.Log(d) / math.Log(newtonTolerance))
+				}
+				return v, r + 1
+			}
+		}
+	}
+	return Inside, 0
+}
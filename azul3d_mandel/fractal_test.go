@@ -0,0 +1,120 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// escapeTests are reference escape-time values of each fractal, worked out by
+// hand from the first few iterations of their orbits.
+var escapeTests = []struct {
+	f     Fractal
+	c     complex128
+	v     float64
+	basin int
+}{
+	// 0, 1, 2, 5: escapes at the third iteration.
+	{Mandelbrot{}, 1, 2 - math.Log2(math.Log2(5)), 0},
+	// 0, 2i, -4+2i.
+	{Mandelbrot{}, 2i, 1 - math.Log2(math.Log2(math.Sqrt(20))), 0},
+	{Mandelbrot{}, 0, Inside, 0},
+	{Mandelbrot{}, -2, Inside, 0},                    // 0, -2, 2, 2, ...
+	{Mandelbrot{}, 1i, Inside, 0},                    // Periodic: i, -1+i, -i, -1+i, ...
+	{Mandelbrot{}, -1, Inside, 0},                    // In the period-2 bulb.
+	{Julia{C: 0}, 2, -1, 0},                          // 2, 4.
+	{Julia{C: 0}, 0.5, Inside, 0},                    // Converges to zero.
+	{Julia{C: -2}, 2, Inside, 0},                     // 2, 2, ...
+	{Julia{C: 1}, 1, 1 - math.Log2(math.Log2(5)), 0}, // 1, 2, 5.
+	// 0, -1+i, (|-1|+|1|i)^2-1+i = -1+3i.
+	{BurningShip{}, complex(-1, 1), 1 - math.Log2(math.Log2(math.Sqrt(10))), 0},
+	{BurningShip{}, 0, Inside, 0},
+	// 0, i, (-i)^2+i = -1+i, (-1-i)^2+i = 3i.
+	{Tricorn{}, 1i, 2 - math.Log2(math.Log2(3)), 0},
+	{Tricorn{}, 0, Inside, 0},
+	// 0, 1, 2, 9.
+	{Multibrot{Power: 3}, 1, 2 - math.Log(math.Log2(9))/math.Log(3), 0},
+	// 0, -1, -2, -9.
+	{Multibrot{Power: 3}, -1, 2 - math.Log(math.Log2(9))/math.Log(3), 0},
+	{Multibrot{Power: 3}, 0, Inside, 0},
+	// The root 1 is reached after the first step, which leaves it in place.
+	{Newton{}, 1, 0, 1},
+	{Newton{}, 0, Inside, 0}, // The derivative is zero.
+}
+
+func TestEscape(t *testing.T) {
+	for _, interior := range []bool{false, true} {
+		o := Options{MaxIter: 1000, Mode: EscapeTime, Interior: interior}
+		for _, tst := range escapeTests {
+			v, basin := tst.f.Escape(tst.c, o)
+			if !near(v, tst.v) || basin != tst.basin {
+				t.Errorf("%s at %v (interior %v): got %v basin %d, want %v basin %d",
+					tst.f.Name(), tst.c, interior, v, basin, tst.v, tst.basin)
+			}
+		}
+	}
+}
+
+// near reports whether a and b are equal to within rounding errors.
+func near(a, b float64) bool {
+	return a == b || math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// TestInterior checks that interior detection does not change any value.
+func TestInterior(t *testing.T) {
+	for _, f := range Fractals() {
+		for _, mode := range []Mode{EscapeTime, DistanceEstimate} {
+			// The grid is offset to avoid points exactly on the boundary of
+			// the sets, such as -i, whose orbits only escape by rounding.
+			for y := -1.5 + 0.013; y <= 1.5; y += 0.047 {
+				for x := -2.5 + 0.013; x <= 1.5; x += 0.047 {
+					c := complex(x, y)
+					o := Options{MaxIter: 500, Mode: mode, PixelSize: 0.05}
+					want, wantBasin := f.Escape(c, o)
+					o.Interior = true
+					got, basin := f.Escape(c, o)
+					if !near(got, want) || basin != wantBasin {
+						t.Fatalf("%s %v at %v: %v basin %d with interior detection, %v basin %d without",
+							f.Name(), mode, c, got, basin, want, wantBasin)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestDistanceEstimate checks that the estimated distance to each fractal is
+// Inside for points inside it, and grows with the distance to it along the
+// real axis outside of it.
+func TestDistanceEstimate(t *testing.T) {
+	o := Options{MaxIter: 1000, Mode: DistanceEstimate, PixelSize: 1}
+	for _, f := range []Fractal{Mandelbrot{}, Julia{C: 0}, Multibrot{Power: 3}} {
+		if v, _ := f.Escape(0, o); v != Inside {
+			t.Errorf("%s at 0: got %v, want Inside", f.Name(), v)
+		}
+		prev := 0.0
+		for x := 2.0; x <= 64; x *= 2 {
+			v, _ := f.Escape(complex(x, 0), o)
+			if v == Inside || math.IsNaN(v) || v <= prev {
+				t.Errorf("%s at %v: got %v, want more than %v", f.Name(), x, v, prev)
+			}
+			prev = v
+		}
+	}
+}
+
+// TestNewtonBasins checks that points near each root of Newton converge to it.
+func TestNewtonBasins(t *testing.T) {
+	o := Options{MaxIter: 100}
+	for i, root := range newtonRoots {
+		for _, d := range []complex128{0.1, -0.1i, complex(-0.05, 0.05)} {
+			v, basin := Newton{}.Escape(root+d, o)
+			if basin != i+1 || v == Inside || v > 10 {
+				t.Errorf("%v: got %v basin %d, want basin %d within 10 iterations", root+d, v, basin, i+1)
+			}
+		}
+	}
+}
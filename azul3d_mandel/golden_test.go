@@ -0,0 +1,21 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"azul3d.org/examples/golden"
+	"azul3d.org/examples/headless"
+)
+
+func TestGolden(t *testing.T) {
+	golden.Run(t, golden.Example{
+		GfxLoop: gfxLoop,
+		Shaders: map[string]headless.FragmentShader{
+			"azul3d_mandel/mandel": headless.TextureShader,
+		},
+	})
+}
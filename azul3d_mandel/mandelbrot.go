@@ -5,49 +5,193 @@
 package main
 
 import (
+	"context"
 	"image"
-	"image/color"
 	"math"
+	"runtime"
+	"sort"
+	"sync"
 )
 
-// Calculates and returns a mandelbrot image.
-func Mandelbrot(w, h, maxIterations int, zoom, posX, posY float64) *image.RGBA {
-	var (
-		img                        = image.NewRGBA(image.Rect(0, 0, w, h))
-		pr, pi                     float64
-		newRe, newIm, oldRe, oldIm float64
-		fw, fh                     = float64(w), float64(h)
-	)
-
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			fx := float64(x)
-			fy := float64(y)
-			pr = 1.5*(fx-fw/2)/(0.5*zoom*fw) + posX
-			pi = (fy-fh/2)/(0.5*zoom*fh) + posY
-			newRe = 0
-			newIm = 0
-			oldRe = 0
-			oldIm = 0
-			var i int
-			for i = 0; i < maxIterations; i++ {
-				oldRe = newRe
-				oldIm = newIm
-				newRe = oldRe*oldRe - oldIm*oldIm + pr
-				newIm = 2*oldRe*oldIm + pi
-				if (newRe*newRe + newIm*newIm) > 4 {
-					break
+// Inside is the field value of points that never escape, i.e. that are inside
+// the set.
+const Inside = math.MaxFloat64
+
+// Field is the raw result of the generator: one smooth escape-time value per
+// pixel, which a Palette turns into colors.
+type Field struct {
+	Rect    image.Rectangle
+	MaxIter int
+
+	// Values holds the value of each pixel in row-major order: the smooth
+	// number of iterations after which the point escaped, Inside, or NaN if it
+	// has not been calculated yet.
+	Values []float64
+
+	// Basins holds the basin of each pixel in row-major order, for fractals
+	// whose points converge to one of several attractors (see Fractal).
+	Basins []uint8
+}
+
+// NewField returns a new field with no calculated values.
+func NewField(r image.Rectangle, maxIter int) *Field {
+	f := &Field{
+		Rect:    r,
+		MaxIter: maxIter,
+		Values:  make([]float64, r.Dx()*r.Dy()),
+		Basins:  make([]uint8, r.Dx()*r.Dy()),
+	}
+	for i := range f.Values {
+		f.Values[i] = math.NaN()
+	}
+	return f
+}
+
+// offset returns the index of the pixel at x, y in Values and Basins.
+func (f *Field) offset(x, y int) int {
+	return (y-f.Rect.Min.Y)*f.Rect.Dx() + (x - f.Rect.Min.X)
+}
+
+// At returns the value and basin of the pixel at x, y.
+func (f *Field) At(x, y int) (v float64, basin int) {
+	i := f.offset(x, y)
+	return f.Values[i], int(f.Basins[i])
+}
+
+// Set sets the value and basin of the pixel at x, y.
+func (f *Field) Set(x, y int, v float64, basin int) {
+	i := f.offset(x, y)
+	f.Values[i] = v
+	f.Basins[i] = uint8(basin)
+}
+
+// Draw copies the pixels of src into f where the two overlap.
+func (f *Field) Draw(src *Field) {
+	r := f.Rect.Intersect(src.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			v, basin := src.At(x, y)
+			f.Set(x, y, v, basin)
+		}
+	}
+}
+
+// Copy returns a copy of the field.
+func (f *Field) Copy() *Field {
+	cpy := *f
+	cpy.Values = append([]float64(nil), f.Values...)
+	cpy.Basins = append([]uint8(nil), f.Basins...)
+	return &cpy
+}
+
+// tileSize is the width and height of the tiles that images are split into
+// for rendering.
+const tileSize = 32
+
+// Generate calculates the field of a w*h image of the fractal in the view. Past
+// deepZoom the center of the view is used with its full precision for the
+// Mandelbrot set, by way of perturbation (see
+// reference, which always uses the EscapeTime mode); other fractals are
+// limited to float64 precision.
+//
+// The image is split into tiles, which are rendered in parallel by one
+// goroutine per CPU starting at the center of the image. Each tile is passed to
+// tileDone once it is complete, possibly from several goroutines at once; its
+// Rect is its position in the whole image.
+//
+// If ctx is canceled rendering stops early and ctx.Err() is returned.
+func Generate(ctx context.Context, f Fractal, o Options, v View, w, h int, tileDone func(tile *Field)) error {
+	o.PixelSize = v.PixelSize(w)
+	delta := func(x, y int) (dr, di float64) {
+		return v.Delta(float64(x), float64(y), w, h)
+	}
+
+	var pixel func(x, y int) (float64, int)
+	if _, ok := f.(Mandelbrot); ok && math.Abs(v.Zoom) >= deepZoom {
+		dr, di := delta(0, 0)
+		ref := newReference(v.X, v.Y, o.MaxIter, math.Hypot(dr, di))
+		pixel = func(x, y int) (float64, int) {
+			return ref.escape(complex(delta(x, y))), 0
+		}
+	} else {
+		cr, _ := v.X.Float64()
+		ci, _ := v.Y.Float64()
+		pixel = func(x, y int) (float64, int) {
+			dr, di := delta(x, y)
+			return f.Escape(complex(dr+cr, di+ci), o)
+		}
+	}
+	return renderTiles(ctx, image.Rect(0, 0, w, h), o.MaxIter, pixel, tileDone)
+}
+
+// renderTiles calculates a field in parallel tiles using the pixel function,
+// as described by Generate.
+func renderTiles(ctx context.Context, r image.Rectangle, maxIterations int, pixel func(x, y int) (float64, int), tileDone func(tile *Field)) error {
+	tiles := splitTiles(r)
+	work := make(chan image.Rectangle)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range work {
+				tile := NewField(r, maxIterations)
+				if !renderTile(ctx, tile, pixel) {
+					continue
 				}
+				tileDone(tile)
 			}
+		}()
+	}
 
-			if i == maxIterations {
-				img.Set(x, y, color.Black)
-			} else {
-				z := math.Sqrt(newRe*newRe + newIm*newIm)
-				brightness := uint8(256.0 * math.Log2(1.75+float64(i)-math.Log2(math.Log2(z))) / math.Log2(float64(maxIterations)))
-				img.Set(x, y, color.RGBA{brightness, brightness, 255, 255})
-			}
+	// Hand out the tiles until we're done or canceled.
+feed:
+	for _, r := range tiles {
+		select {
+		case work <- r:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// splitTiles splits r into tiles, sorted by their distance to the center of r
+// such that the image fills in from the center outwards.
+func splitTiles(r image.Rectangle) []image.Rectangle {
+	var tiles []image.Rectangle
+	for y := r.Min.Y; y < r.Max.Y; y += tileSize {
+		for x := r.Min.X; x < r.Max.X; x += tileSize {
+			tiles = append(tiles, image.Rect(x, y, x+tileSize, y+tileSize).Intersect(r))
+		}
+	}
+	center := r.Min.Add(r.Max).Div(2)
+	dist := func(t image.Rectangle) int {
+		d := t.Min.Add(t.Max).Div(2).Sub(center)
+		return d.X*d.X + d.Y*d.Y
+	}
+	sort.SliceStable(tiles, func(i, j int) bool {
+		return dist(tiles[i]) < dist(tiles[j])
+	})
+	return tiles
+}
+
+// renderTile calculates the values of the tile using the pixel function. It
+// returns false if ctx was canceled before the tile was completed.
+func renderTile(ctx context.Context, tile *Field, pixel func(x, y int) (float64, int)) bool {
+	b := tile.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		// Check for cancellation once per row, which is frequent enough to
+		// abort stale frames quickly even at high iteration counts.
+		if ctx.Err() != nil {
+			return false
+		}
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v, basin := pixel(x, y)
+			tile.Set(x, y, v, basin)
 		}
 	}
-	return img
+	return true
 }
@@ -0,0 +1,30 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// benchGenerate generates the initial view of the example at 320x180, with or
+// without interior detection. Most of the view is inside the set, which is
+// where interior detection saves time.
+func benchGenerate(b *testing.B, interior bool) {
+	v, err := parseView("-0.5,0", 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	o := Options{MaxIter: 1000, Mode: EscapeTime, Interior: interior}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Generate(context.Background(), Mandelbrot{}, o, v, 320, 180, func(*Field) {}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInterior(b *testing.B)   { benchGenerate(b, true) }
+func BenchmarkNoInterior(b *testing.B) { benchGenerate(b, false) }
@@ -0,0 +1,127 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// Mode is how the orbit of a point is turned into its field value.
+type Mode int
+
+const (
+	// EscapeTime is the smooth number of iterations after which the orbit
+	// escaped.
+	EscapeTime Mode = iota
+
+	// DistanceEstimate is the estimated distance from the point to the
+	// boundary of the set in pixels, which renders the boundary crisply at any
+	// zoom. Fractals without a complex derivative use EscapeTime instead.
+	DistanceEstimate
+
+	// OrbitTrap is how close the orbit came to the trap (the axes and the
+	// origin), on a logarithmic scale. Unlike the other modes it gives points
+	// inside the set a value, too.
+	OrbitTrap
+)
+
+// modeNames are the names of each mode.
+var modeNames = []string{"escape", "distance", "trap"}
+
+// String returns the name of the mode, e.g. "escape".
+func (m Mode) String() string {
+	if int(m) < len(modeNames) {
+		return modeNames[m]
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// parseMode returns the mode with the given name.
+func parseMode(name string) (Mode, error) {
+	for i, n := range modeNames {
+		if n == name {
+			return Mode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown mode %q", name)
+}
+
+// Options are the options for calculating the field value of a point.
+type Options struct {
+	// MaxIter is the maximum number of iterations.
+	MaxIter int
+
+	// Mode is how the orbit is turned into the field value.
+	Mode Mode
+
+	// Interior enables detecting points inside the set without iterating them
+	// until MaxIter, where the fractal supports it. It only affects speed.
+	Interior bool
+
+	// PixelSize is the distance between two pixels in the complex plane, as
+	// set by Generate.
+	PixelSize float64
+}
+
+// deBailout is the escape radius used for distance estimation, which is only
+// accurate once the orbit is far away from the set.
+const deBailout = 1 << 16
+
+// trapScale scales the logarithmic orbit trap distance to a range similar to
+// that of iteration counts, such that palettes work with either.
+const trapScale = 8
+
+// polynomial is the iteration of a polynomial fractal, from which the field
+// value of a point can be calculated in any mode.
+type polynomial struct {
+	// step calculates the next z of the orbit of c.
+	step func(z, c complex128) complex128
+
+	// deriv calculates the next derivative dz (with respect to c) of the
+	// orbit, given the current z and dz. It is nil if the fractal has no
+	// complex derivative.
+	deriv func(z, dz complex128) complex128
+
+	// power is the degree of the polynomial.
+	power float64
+}
+
+// orbit returns the field value of the orbit of c starting at z, whose
+// derivative starts at dz.
+func (p polynomial) orbit(z, dz, c complex128, o Options) float64 {
+	switch {
+	case o.Mode == DistanceEstimate && p.deriv != nil:
+		for i := 0; i < o.MaxIter; i++ {
+			dz = p.deriv(z, dz)
+			z = p.step(z, c)
+			if r := cmplx.Abs(z); r > deBailout {
+				return r * math.Log(r) / (p.power * cmplx.Abs(dz)) / o.PixelSize
+			}
+		}
+		return Inside
+
+	case o.Mode == OrbitTrap:
+		trap := math.Inf(1)
+		for i := 0; i < o.MaxIter; i++ {
+			z = p.step(z, c)
+			trap = math.Min(trap, math.Min(cmplx.Abs(z), math.Min(math.Abs(real(z)), math.Abs(imag(z)))))
+			if real(z)*real(z)+imag(z)*imag(z) > 4 {
+				break
+			}
+		}
+		return math.Max(0, -trapScale*math.Log2(math.Max(trap, 1e-12)))
+
+	default:
+		for i := 0; i < o.MaxIter; i++ {
+			z = p.step(z, c)
+			if real(z)*real(z)+imag(z)*imag(z) > 4 {
+				return smooth(i, z, p.power)
+			}
+		}
+		return Inside
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Palette colors the values of a field.
+type Palette interface {
+	// Name returns the name of the palette, e.g. "cyclic:fire".
+	Name() string
+
+	// Colorize returns an image of the field colored by the palette. Pixels
+	// whose value has not been calculated yet are left transparent.
+	Colorize(f *Field) *image.RGBA
+}
+
+// basinColors are the hues that pixels are tinted with by their basin, for
+// fractals that have them.
+var basinColors = []color.RGBA{
+	{255, 80, 60, 255},
+	{80, 220, 90, 255},
+	{70, 120, 255, 255},
+	{240, 200, 50, 255},
+}
+
+// colorize returns an image of the field with each calculated value colored
+// by fn. Points inside the set are black, and points in a basin are tinted by
+// the color of their basin with the brightness of fn.
+func colorize(f *Field, fn func(v float64) color.RGBA) *image.RGBA {
+	img := image.NewRGBA(f.Rect)
+	for i, v := range f.Values {
+		var c color.RGBA
+		switch {
+		case math.IsNaN(v):
+			continue
+		case v == Inside:
+			c = color.RGBA{0, 0, 0, 255}
+		default:
+			c = fn(v)
+		}
+		if b := f.Basins[i]; b > 0 {
+			tint := basinColors[int(b-1)%len(basinColors)]
+			l := (0.3*float64(c.R) + 0.59*float64(c.G) + 0.11*float64(c.B)) / 255
+			l = 0.25 + 0.75*l
+			c = color.RGBA{uint8(float64(tint.R) * l), uint8(float64(tint.G) * l), uint8(float64(tint.B) * l), 255}
+		}
+		img.Pix[i*4+0] = c.R
+		img.Pix[i*4+1] = c.G
+		img.Pix[i*4+2] = c.B
+		img.Pix[i*4+3] = c.A
+	}
+	return img
+}
+
+// Classic is the original palette of the example: blue, getting brighter
+// logarithmically with the number of iterations.
+type Classic struct{}
+
+// Name implements the Palette interface.
+func (Classic) Name() string { return "classic" }
+
+// Colorize implements the Palette interface.
+func (Classic) Colorize(f *Field) *image.RGBA {
+	maxLog := math.Log2(float64(f.MaxIter))
+	return colorize(f, func(v float64) color.RGBA {
+		brightness := uint8(256.0 * math.Log2(1.75+v) / maxLog)
+		return color.RGBA{brightness, brightness, 255, 255}
+	})
+}
+
+// Cyclic is a palette that repeats the gradient every Period iterations, which
+// keeps detail visible at any iteration count.
+type Cyclic struct {
+	Gradient *Gradient
+	Period   float64
+}
+
+// Name implements the Palette interface.
+func (c Cyclic) Name() string { return "cyclic:" + c.Gradient.Name }
+
+// Colorize implements the Palette interface.
+func (c Cyclic) Colorize(f *Field) *image.RGBA {
+	return colorize(f, func(v float64) color.RGBA {
+		t := math.Mod(v/c.Period, 1)
+		if t < 0 {
+			t++
+		}
+		return c.Gradient.At(t)
+	})
+}
+
+// Histogram is a palette that spreads the gradient evenly over the escaped
+// points of the field (i.e. histogram equalization), such that each color
+// covers about the same area of the image regardless of the view.
+type Histogram struct {
+	Gradient *Gradient
+}
+
+// Name implements the Palette interface.
+func (h Histogram) Name() string { return "histogram:" + h.Gradient.Name }
+
+// Colorize implements the Palette interface.
+func (h Histogram) Colorize(f *Field) *image.RGBA {
+	// Sort the escaped values, the position of a value in the sorted list is
+	// then its position in the gradient.
+	sorted := make([]float64, 0, len(f.Values))
+	for _, v := range f.Values {
+		if !math.IsNaN(v) && v != Inside {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.Float64s(sorted)
+	n := float64(len(sorted))
+	return colorize(f, func(v float64) color.RGBA {
+		return h.Gradient.At(float64(sort.SearchFloat64s(sorted, v)) / n)
+	})
+}
+
+// Stop is a single color stop of a gradient.
+type Stop struct {
+	Pos   float64 // Position of the stop in the gradient, from 0 to 1.
+	Color color.RGBA
+}
+
+// Gradient is a color gradient, linearly interpolated between its stops.
+type Gradient struct {
+	Name  string
+	Stops []Stop // Sorted by position.
+}
+
+// At returns the color at the position t (from 0 to 1) in the gradient.
+func (g *Gradient) At(t float64) color.RGBA {
+	i := sort.Search(len(g.Stops), func(i int) bool {
+		return g.Stops[i].Pos >= t
+	})
+	switch {
+	case len(g.Stops) == 0:
+		return color.RGBA{0, 0, 0, 255}
+	case i == 0:
+		return g.Stops[0].Color
+	case i == len(g.Stops):
+		return g.Stops[i-1].Color
+	}
+	a, b := g.Stops[i-1], g.Stops[i]
+	s := (t - a.Pos) / (b.Pos - a.Pos)
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + s*(float64(b)-float64(a)) + 0.5)
+	}
+	return color.RGBA{
+		lerp(a.Color.R, b.Color.R),
+		lerp(a.Color.G, b.Color.G),
+		lerp(a.Color.B, b.Color.B),
+		lerp(a.Color.A, b.Color.A),
+	}
+}
+
+// ParseGradient parses a gradient file. Each line of the file holds a stop: a
+// position from 0 to 1 and a hex RGB color, e.g.:
+//
+//	# Black to white.
+//	0.0 000000
+//	1.0 ffffff
+//
+// Blank lines and lines starting with # are ignored.
+func ParseGradient(name string, r io.Reader) (*Gradient, error) {
+	g := &Gradient{Name: name}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want position and color", name, line)
+		}
+		pos, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || pos < 0 || pos > 1 {
+			return nil, fmt.Errorf("%s:%d: invalid position %q", name, line, fields[0])
+		}
+		rgb, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil || len(fields[1]) != 6 {
+			return nil, fmt.Errorf("%s:%d: invalid color %q", name, line, fields[1])
+		}
+		g.Stops = append(g.Stops, Stop{
+			Pos:   pos,
+			Color: color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255},
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(g.Stops) == 0 {
+		return nil, fmt.Errorf("%s: no color stops", name)
+	}
+	sort.SliceStable(g.Stops, func(i, j int) bool {
+		return g.Stops[i].Pos < g.Stops[j].Pos
+	})
+	return g, nil
+}
+
+// LoadPalettes returns the classic palette followed by a cyclic and a
+// histogram palette for each gradient file (*.grad) in dir of fsys.
+func LoadPalettes(fsys fs.FS, dir string) ([]Palette, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.grad"))
+	if err != nil {
+		return nil, err
+	}
+	palettes := []Palette{Classic{}}
+	for _, file := range files {
+		f, err := fsys.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		g, err := ParseGradient(strings.TrimSuffix(path.Base(file), ".grad"), f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		palettes = append(palettes, Histogram{Gradient: g}, Cyclic{Gradient: g, Period: 64})
+	}
+	return palettes, nil
+}
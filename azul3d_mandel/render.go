@@ -0,0 +1,190 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"azul3d.org/examples/abs"
+)
+
+// Flags specifying the initial view, which are also used to render an image
+// from the command line with -render (or -flight, for the size, supersampling
+// and palette).
+var (
+	flagRender  = flag.String("render", "", "render the view to this PNG file and exit, without opening a window")
+	flagFractal = flag.String("fractal", "mandelbrot", "fractal name: mandelbrot, julia, burningship, tricorn, multibrot3 or newton")
+	flagMode    = flag.String("mode", "escape", "mode: escape (escape time), distance (distance estimation) or trap (orbit traps)")
+	flagCenter  = flag.String("center", "-0.5,0", "center of the view, as real,imaginary (with any number of digits)")
+	flagZoom    = flag.Float64("zoom", 1, "zoom of the view")
+	flagIter    = flag.Int("iter", 1000, "maximum number of generator iterations")
+	flagPalette = flag.String("palette", "classic", "palette name, e.g. classic, histogram:fire or cyclic:ocean")
+	flagSize    = flag.String("size", "1920x1080", "size of the image rendered by -render, as WIDTHxHEIGHT")
+	flagSS      = flag.Int("ss", 1, "supersampling factor of -render, each pixel is the average of ss*ss samples")
+)
+
+// parseView parses the view with the given zoom and a center point given as
+// "real,imaginary", with enough precision for the zoom.
+func parseView(center string, zoom float64) (View, error) {
+	parts := strings.Split(center, ",")
+	if len(parts) != 2 {
+		return View{}, fmt.Errorf("invalid center %q: want real,imaginary", center)
+	}
+	prec := precision(zoom)
+	x, _, err := big.ParseFloat(strings.TrimSpace(parts[0]), 10, prec, big.ToNearestEven)
+	if err != nil {
+		return View{}, fmt.Errorf("invalid center %q: %v", center, err)
+	}
+	y, _, err := big.ParseFloat(strings.TrimSpace(parts[1]), 10, prec, big.ToNearestEven)
+	if err != nil {
+		return View{}, fmt.Errorf("invalid center %q: %v", center, err)
+	}
+	return View{X: x, Y: y, Zoom: zoom}, nil
+}
+
+// parseSize parses an image size given as "WIDTHxHEIGHT".
+func parseSize(s string) (width, height int, err error) {
+	_, err = fmt.Sscanf(s, "%dx%d", &width, &height)
+	if err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q: want WIDTHxHEIGHT", s)
+	}
+	return width, height, nil
+}
+
+// findPalette returns the index of the palette with the given name.
+func findPalette(palettes []Palette, name string) (int, error) {
+	var names []string
+	for i, p := range palettes {
+		if p.Name() == name {
+			return i, nil
+		}
+		names = append(names, p.Name())
+	}
+	return 0, fmt.Errorf("unknown palette %q, choose one of: %s", name, strings.Join(names, ", "))
+}
+
+// loadPalette loads the palettes and returns them with the index of the one
+// with the given name.
+func loadPalette(name string) ([]Palette, int, error) {
+	palettes, err := LoadPalettes(abs.FS(), "azul3d_mandel/gradients")
+	if err != nil {
+		return nil, 0, err
+	}
+	palette, err := findPalette(palettes, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return palettes, palette, nil
+}
+
+// renderFile renders the view specified by the flags with the CPU generator
+// and writes it to a PNG file at path.
+func renderFile(path string) error {
+	width, height, err := parseSize(*flagSize)
+	if err != nil {
+		return err
+	}
+	v, err := parseView(*flagCenter, *flagZoom)
+	if err != nil {
+		return err
+	}
+	palettes, palette, err := loadPalette(*flagPalette)
+	if err != nil {
+		return err
+	}
+	f, o, err := fractalFromFlags()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	img, err := renderImage(f, o, v, palettes[palette], width, height, *flagSS)
+	if err != nil {
+		return err
+	}
+	log.Printf("Rendered %dx%d (%dx supersampled) in %v\n", width, height, *flagSS, time.Since(start))
+	return writePNG(path, img)
+}
+
+// fractalFromFlags returns the fractal and options specified by the flags.
+func fractalFromFlags() (Fractal, Options, error) {
+	fractals := Fractals()
+	fractal, err := findFractal(fractals, *flagFractal)
+	if err != nil {
+		return nil, Options{}, err
+	}
+	mode, err := parseMode(*flagMode)
+	if err != nil {
+		return nil, Options{}, err
+	}
+	return fractals[fractal], Options{MaxIter: *flagIter, Mode: mode, Interior: true}, nil
+}
+
+// renderImage renders an image of the fractal with the CPU generator,
+// supersampled by the factor ss.
+func renderImage(f Fractal, o Options, v View, p Palette, width, height, ss int) (*image.RGBA, error) {
+	if ss < 1 {
+		return nil, fmt.Errorf("invalid supersampling factor %d", ss)
+	}
+
+	// Generate the field at the supersampled size.
+	field := NewField(image.Rect(0, 0, width*ss, height*ss), o.MaxIter)
+	err := Generate(context.Background(), f, o, v, width*ss, height*ss, field.Draw)
+	if err != nil {
+		return nil, err
+	}
+	return downsample(p.Colorize(field), ss), nil
+}
+
+// writePNG writes the image to a PNG file at path.
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// downsample returns the image scaled down by the factor ss, with each pixel
+// being the average of the ss*ss pixels it covers.
+func downsample(img *image.RGBA, ss int) *image.RGBA {
+	if ss == 1 {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()/ss, b.Dy()/ss))
+	n := uint32(ss * ss)
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			var sum [4]uint32
+			for sy := 0; sy < ss; sy++ {
+				i := img.PixOffset(b.Min.X+x*ss, b.Min.Y+y*ss+sy)
+				for sx := 0; sx < ss; sx++ {
+					for c := range sum {
+						sum[c] += uint32(img.Pix[i+sx*4+c])
+					}
+				}
+			}
+			j := dst.PixOffset(x, y)
+			for c := range sum {
+				dst.Pix[j+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
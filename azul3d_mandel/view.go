@@ -0,0 +1,72 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/big"
+)
+
+// View is the region of the complex plane shown by an image: at zoom 1 it is 3
+// wide around the center X, Y, and as high as the aspect ratio of the image
+// makes it (2 high for a 3:2 image), such that pixels are square.
+//
+// Views map pixels of an image of any size to points, such that the same view
+// is used for generating an image (at any resolution) and for handling input
+// in window coordinates. Their methods return new views, leaving the original
+// untouched.
+type View struct {
+	X, Y *big.Float // Center.
+	Zoom float64
+}
+
+// Delta returns the offset from the center of the point at pixel x, y of a w*h
+// image of the view.
+func (v View) Delta(x, y float64, w, h int) (dr, di float64) {
+	s := 1.5 / (0.5 * v.Zoom * float64(w))
+	dr = (x - float64(w)/2) * s
+	di = (y - float64(h)/2) * s
+	return
+}
+
+// At returns the point at pixel x, y of a w*h image of the view.
+func (v View) At(x, y float64, w, h int) (re, im *big.Float) {
+	dr, di := v.Delta(x, y, w, h)
+	return v.offset(dr, di)
+}
+
+// PixelSize returns the distance between two pixels of a w pixels wide image of
+// the view, in the complex plane.
+func (v View) PixelSize(w int) float64 {
+	return 1.5 / (0.5 * math.Abs(v.Zoom) * float64(w))
+}
+
+// Pan returns the view moved by dx, dy pixels of a w*h image of it.
+func (v View) Pan(dx, dy float64, w, h int) View {
+	dr, di := v.Delta(float64(w)/2+dx, float64(h)/2+dy, w, h)
+	v.X, v.Y = v.offset(dr, di)
+	return v
+}
+
+// ZoomAt returns the view zoomed in by the factor (or out, if it is less than
+// one), keeping the point at pixel x, y of a w*h image where it is.
+func (v View) ZoomAt(factor, x, y float64, w, h int) View {
+	// The offset of the point from the center shrinks with the zoom, so the
+	// center moves towards it by the difference.
+	dr, di := v.Delta(x, y, w, h)
+	s := 1 - 1/factor
+	v.Zoom *= factor
+	v.X, v.Y = v.offset(dr*s, di*s)
+	return v
+}
+
+// offset returns the center moved by dr, di, with enough precision for the
+// zoom of the view.
+func (v View) offset(dr, di float64) (re, im *big.Float) {
+	prec := precision(v.Zoom)
+	re = new(big.Float).SetPrec(prec).Add(v.X, big.NewFloat(dr))
+	im = new(big.Float).SetPrec(prec).Add(v.Y, big.NewFloat(di))
+	return re, im
+}
@@ -0,0 +1,193 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"image"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"azul3d.org/engine/keyboard"
+	"azul3d.org/engine/mouse"
+)
+
+// viewTests are views at shallow and deep zooms, the latter needing more
+// precision than a float64 has.
+var viewTests = []struct {
+	center string
+	zoom   float64
+}{
+	{"-0.5,0", 1},
+	{"0.25,-0.75", 0.5},
+	{"-0.743643887037158704752191506114774,0.131825904205311970493132056385139", 1e20},
+}
+
+// pixel returns the pixel of a w*h image of the view at the point re, im. It is
+// the inverse of View.At.
+func pixel(v View, re, im *big.Float, w, h int) (x, y float64) {
+	dr, _ := new(big.Float).Sub(re, v.X).Float64()
+	di, _ := new(big.Float).Sub(im, v.Y).Float64()
+	s := 0.5 * v.Zoom * float64(w) / 1.5
+	x = dr*s + float64(w)/2
+	y = di*s + float64(h)/2
+	return
+}
+
+// nearPixel reports whether x, y is within a millionth of a pixel of p.
+func nearPixel(x, y float64, p [2]float64) bool {
+	return math.Abs(x-p[0]) < 1e-6 && math.Abs(y-p[1]) < 1e-6
+}
+
+func mustParseView(t *testing.T, center string, zoom float64) View {
+	v, err := parseView(center, zoom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestViewAt(t *testing.T) {
+	const w, h = 640, 360
+	for _, tst := range viewTests {
+		v := mustParseView(t, tst.center, tst.zoom)
+
+		// The center of the image is the center of the view.
+		re, im := v.At(w/2, h/2, w, h)
+		if re.Cmp(v.X) != 0 || im.Cmp(v.Y) != 0 {
+			t.Errorf("%s zoom %v: center at %v,%v", tst.center, tst.zoom, re, im)
+		}
+
+		// The corners are 1.5 away from it horizontally and, with square
+		// pixels, 1.5*h/w vertically, divided by the zoom.
+		dr, di := v.Delta(0, 0, w, h)
+		if !near(dr, -1.5/tst.zoom) || !near(di, -1.5*h/w/tst.zoom) {
+			t.Errorf("%s zoom %v: top-left corner at %v,%v from the center", tst.center, tst.zoom, dr, di)
+		}
+
+		for _, p := range [][2]float64{{0, 0}, {w, h}, {12.5, 300.25}, {639, 1}} {
+			re, im := v.At(p[0], p[1], w, h)
+			x, y := pixel(v, re, im, w, h)
+			if !nearPixel(x, y, p) {
+				t.Errorf("%s zoom %v: pixel %v is at %v,%v, which is at pixel %v,%v", tst.center, tst.zoom, p, re, im, x, y)
+			}
+		}
+	}
+}
+
+func TestViewZoomAt(t *testing.T) {
+	const w, h = 640, 360
+	for _, tst := range viewTests {
+		v := mustParseView(t, tst.center, tst.zoom)
+		for _, factor := range []float64{2, 0.5, 1.1} {
+			for _, p := range [][2]float64{{0, 0}, {w / 2, h / 2}, {100, 250}} {
+				re, im := v.At(p[0], p[1], w, h)
+				z := v.ZoomAt(factor, p[0], p[1], w, h)
+				if !near(z.Zoom, v.Zoom*factor) {
+					t.Errorf("%s zoom %v by %v: got zoom %v", tst.center, tst.zoom, factor, z.Zoom)
+				}
+				x, y := pixel(z, re, im, w, h)
+				if !nearPixel(x, y, p) {
+					t.Errorf("%s zoom %v by %v at %v: the point moved to %v,%v", tst.center, tst.zoom, factor, p, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestViewPan(t *testing.T) {
+	const w, h = 640, 360
+	for _, tst := range viewTests {
+		v := mustParseView(t, tst.center, tst.zoom)
+		re, im := v.At(100, 50, w, h)
+		p := v.Pan(100-w/2, 50-h/2, w, h)
+		if re.Cmp(p.X) != 0 || im.Cmp(p.Y) != 0 {
+			t.Errorf("%s zoom %v: panned to %v,%v, want %v,%v", tst.center, tst.zoom, p.X, p.Y, re, im)
+		}
+	}
+}
+
+// sameView reports whether a and b are the same view.
+func sameView(a, b View) bool {
+	return a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0 && a.Zoom == b.Zoom
+}
+
+func TestHistory(t *testing.T) {
+	home := mustParseView(t, "-0.5,0", 1)
+	m := &mandelGen{
+		fractals:   Fractals(),
+		view:       home,
+		home:       home,
+		resolution: 1,
+		bounds:     image.Rect(0, 0, 640, 360),
+	}
+	typed := func(s string) { m.handle(keyboard.Typed{S: s}) }
+
+	// Zoom three times, far enough apart in time not to be coalesced.
+	views := []View{m.view}
+	for i := 0; i < 3; i++ {
+		m.lastChange = time.Time{}
+		m.handle(mouse.Scrolled{Y: 1})
+		views = append(views, m.view)
+	}
+	if sameView(views[0], views[1]) {
+		t.Fatal("scrolling did not change the view")
+	}
+
+	typed("u")
+	typed("u")
+	if !sameView(m.view, views[1]) {
+		t.Errorf("undo twice: got %v, want %v", m.view, views[1])
+	}
+	typed("r")
+	if !sameView(m.view, views[2]) {
+		t.Errorf("redo: got %v, want %v", m.view, views[2])
+	}
+
+	// Undoing or redoing past the end of the history does nothing.
+	for i := 0; i < 5; i++ {
+		typed("r")
+	}
+	if !sameView(m.view, views[3]) {
+		t.Errorf("redo past the end: got %v, want %v", m.view, views[3])
+	}
+	for i := 0; i < 5; i++ {
+		typed("u")
+	}
+	if !sameView(m.view, views[0]) {
+		t.Errorf("undo past the start: got %v, want %v", m.view, views[0])
+	}
+
+	// A new change drops the views that could be redone.
+	typed("r")
+	m.lastChange = time.Time{}
+	m.handle(mouse.Scrolled{Y: -1})
+	if len(m.redo) != 0 {
+		t.Errorf("%d views to redo after a change, want none", len(m.redo))
+	}
+
+	// Reset goes home, and can be undone.
+	changed := m.view
+	typed("0")
+	if !sameView(m.view, home) {
+		t.Errorf("reset: got %v, want %v", m.view, home)
+	}
+	typed("u")
+	if !sameView(m.view, changed) {
+		t.Errorf("undo reset: got %v, want %v", m.view, changed)
+	}
+
+	// Changes in quick succession are undone at once.
+	m.lastChange = time.Time{}
+	before := m.view
+	for i := 0; i < 3; i++ {
+		m.handle(mouse.Scrolled{Y: 1})
+	}
+	typed("u")
+	if !sameView(m.view, before) {
+		t.Errorf("undo coalesced changes: got %v, want %v", m.view, before)
+	}
+}
@@ -11,12 +11,12 @@ import (
 
 	"azul3d.org/engine/gfx"
 	"azul3d.org/engine/gfx/camera"
-	"azul3d.org/engine/gfx/gfxutil"
 	"azul3d.org/engine/gfx/window"
 	"azul3d.org/engine/keyboard"
 	"azul3d.org/engine/lmath"
 
 	"azul3d.org/examples/abs"
+	"azul3d.org/examples/timing"
 )
 
 // gfxLoop is responsible for drawing things to the window.
@@ -60,8 +60,8 @@ func gfxLoop(w window.Window, d gfx.Device) {
 		log.Fatal("Graphics hardware does not support render to texture.")
 	}
 
-	// Read the GLSL shaders from disk.
-	shader, err := gfxutil.OpenShader(abs.Path("azul3d_rtt/rtt"))
+	// Read the GLSL shaders.
+	shader, err := abs.OpenShader("azul3d_rtt/rtt")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -134,6 +134,11 @@ func gfxLoop(w window.Window, d gfx.Device) {
 	// Render the rtCanvas to the rtColor texture.
 	rtCanvas.Render()
 
+	// The card is rotated at a fixed timestep of 30 updates per second.
+	clk := timing.For(d)
+	stepper := &timing.Stepper{Step: 1.0 / 30, MaxSteps: 10}
+	var prevAngle, angle float64
+
 	for {
 		// Handle each pending event.
 		window.Poll(event, func(e window.Event) {
@@ -155,12 +160,18 @@ func gfxLoop(w window.Window, d gfx.Device) {
 			}
 		})
 
-		// Rotate the card on the Z axis 15 degrees/sec.
+		// Rotate the card on the Z axis 15 degrees/sec, in fixed steps. The
+		// rendered rotation is interpolated between the last two steps so
+		// that it stays smooth at any frame rate.
+		alpha := stepper.Advance(clk.Dt(), func(step float64) {
+			prevAngle = angle
+			angle += 15 * step
+		})
 		rot := card.Rot()
 		card.SetRot(lmath.Vec3{
 			X: rot.X,
 			Y: rot.Y,
-			Z: rot.Z + (15 * d.Clock().Dt()),
+			Z: prevAngle + (angle-prevAngle)*alpha,
 		})
 
 		// Clear color and depth buffers.
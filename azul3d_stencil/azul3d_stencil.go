@@ -8,16 +8,15 @@ package main
 import (
 	_ "image/png"
 	"log"
-	"math/rand"
 	"time"
 
 	"azul3d.org/engine/gfx"
 	"azul3d.org/engine/gfx/camera"
-	"azul3d.org/engine/gfx/gfxutil"
 	"azul3d.org/engine/gfx/window"
 	math "azul3d.org/engine/lmath"
 
 	"azul3d.org/examples/abs"
+	"azul3d.org/examples/timing"
 )
 
 // cardMesh creates and returns a card mesh.
@@ -109,7 +108,7 @@ func loadTexture(path string) *gfx.Texture {
 
 	// Cache does not have the texture, open it now and store it in the cache
 	// for later.
-	tex, err := gfxutil.OpenTexture(path)
+	tex, err := abs.OpenTexture(path, gfx.LinearMipmapLinear, gfx.Linear)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -164,25 +163,25 @@ func isDead(cam *camera.Camera, shape *gfx.Object) bool {
 	return false
 }
 
-var (
-	butterfly = time.Tick(time.Second / 4)
-	other     = time.Tick(time.Second / 2)
-)
+// Tickers for spawning butterflies and other shapes, created by gfxLoop.
+var butterfly, other *timing.Ticker
 
 func updateShapes(d gfx.Device, shader *gfx.Shader, cam *camera.Camera) {
+	rand := timing.Rand()
+
 	// Butterfly.
 	which := 0
 
-	select {
+	switch {
+	case butterfly.Ticked():
+	case other.Ticked():
+		which = int(rand.Float64() * 4)
 	default:
 		return
-	case <-butterfly:
-	case <-other:
-		which = int(rand.Float64() * 4)
 	}
 
 	// Create a shape.
-	shape := createShape(d, abs.Path("azul3d_stencil/shapes.png"), which)
+	shape := createShape(d, "azul3d_stencil/shapes.png", which)
 	shape.Shader = shader
 	shape.SetPos(math.Vec3{0, -1, 0})
 
@@ -235,7 +234,7 @@ l:
 // shader assigned to it.
 func createBackground() *gfx.Object {
 	// Open the background texture.
-	tex, err := gfxutil.OpenTexture(abs.Path("azul3d_stencil/yi_han_cheol.png"))
+	tex, err := abs.OpenTexture("azul3d_stencil/yi_han_cheol.png", gfx.LinearMipmapLinear, gfx.Linear)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -289,8 +288,8 @@ func gfxLoop(w window.Window, d gfx.Device) {
 	}
 	updateCamera()
 
-	// Read the GLSL shaders from disk.
-	shader, err := gfxutil.OpenShader(abs.Path("azul3d_stencil/stencil"))
+	// Read the GLSL shaders.
+	shader, err := abs.OpenShader("azul3d_stencil/stencil")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -299,6 +298,12 @@ func gfxLoop(w window.Window, d gfx.Device) {
 	bgPicture := createBackground()
 	bgPicture.Shader = shader
 
+	// Spawn shapes and move them using the example clock, so runs can be
+	// replayed.
+	clk := timing.For(d)
+	butterfly = timing.NewTicker(clk, time.Second/4)
+	other = timing.NewTicker(clk, time.Second/2)
+
 	// We want to know when the framebuffer is resized so we can update our
 	// camera.
 	events := make(chan window.Event, 64)
@@ -325,7 +330,7 @@ func gfxLoop(w window.Window, d gfx.Device) {
 			}
 
 			// We will move the shape forward a small amount.
-			v := math.Vec3{0, 0, 0.7 * d.Clock().Dt()}
+			v := math.Vec3{0, 0, 0.7 * clk.Dt()}
 
 			// We don't want movement to take scale into account, all shapes
 			// move the same speed no matter how large or small.
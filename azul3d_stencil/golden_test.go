@@ -0,0 +1,35 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"azul3d.org/engine/gfx/window"
+
+	"azul3d.org/examples/golden"
+	"azul3d.org/examples/headless"
+)
+
+func TestGolden(t *testing.T) {
+	// The same properties as main uses, with a stencil buffer.
+	props := window.NewProps()
+	props.SetSize(720, 480)
+	p := window.DefaultProps.Precision()
+	p.StencilBits = 8
+	props.SetPrecision(p)
+
+	golden.Run(t, golden.Example{
+		GfxLoop: gfxLoop,
+		Props:   props,
+		Shaders: map[string]headless.FragmentShader{
+			"azul3d_stencil/stencil": headless.TextureShader,
+		},
+		Images: []string{
+			"azul3d_stencil/shapes.png",
+			"azul3d_stencil/yi_han_cheol.png",
+		},
+	})
+}
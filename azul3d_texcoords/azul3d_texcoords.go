@@ -11,12 +11,12 @@ import (
 
 	"azul3d.org/engine/gfx"
 	"azul3d.org/engine/gfx/camera"
-	"azul3d.org/engine/gfx/gfxutil"
 	"azul3d.org/engine/gfx/window"
 	"azul3d.org/engine/keyboard"
 	"azul3d.org/engine/lmath"
 
 	"azul3d.org/examples/abs"
+	"azul3d.org/examples/reload"
 )
 
 // gfxLoop is responsible for drawing things to the window.
@@ -27,14 +27,14 @@ func gfxLoop(w window.Window, d gfx.Device) {
 	// Move the camera back two units away from the card.
 	cam.SetPos(lmath.Vec3{0, -2, 0})
 
-	// Read the GLSL shaders from disk.
-	shader, err := gfxutil.OpenShader(abs.Path("azul3d_texcoords/texcoords"))
+	// Read the GLSL shaders.
+	shader, err := abs.OpenShader("azul3d_texcoords/texcoords")
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// Open the texture.
-	tex, err := gfxutil.OpenTexture(abs.Path("azul3d_texcoords/texture_coords_1024x1024.png"))
+	tex, err := abs.OpenTexture("azul3d_texcoords/texture_coords_1024x1024.png", gfx.LinearMipmapLinear, gfx.Linear)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -75,16 +75,30 @@ func gfxLoop(w window.Window, d gfx.Device) {
 	card.Textures = []*gfx.Texture{tex}
 	card.Meshes = []*gfx.Mesh{cardMesh}
 
+	// Reload the shaders and texture whenever they are edited on disk.
+	watcher := reload.New(d)
+	watcher.Shader(shader, "azul3d_texcoords/texcoords")
+	watcher.Texture(tex, "azul3d_texcoords/texture_coords_1024x1024.png")
+	defer watcher.Close()
+
 	// Create a channel of events.
 	events := make(chan window.Event, 256)
 
 	// Have the window notify our channel whenever events occur.
-	w.Notify(events, window.FramebufferResizedEvents|window.KeyboardTypedEvents)
+	w.Notify(events, window.FramebufferResizedEvents|window.KeyboardTypedEvents|window.CloseEvents)
 
 	for {
+		// Swap in any reloaded assets.
+		watcher.Update()
+
 		// Handle each pending event.
 		window.Poll(events, func(e window.Event) {
 			switch ev := e.(type) {
+			case window.Close:
+				// The program exits once the window closes, stop watching
+				// first.
+				watcher.Close()
+
 			case window.FramebufferResized:
 				// Update the camera's projection matrix for the new width and
 				// height.
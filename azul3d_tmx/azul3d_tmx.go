@@ -10,7 +10,10 @@ import (
 	"fmt"
 	"image"
 	_ "image/png"
+	"io/fs"
 	"log"
+	"math"
+	"time"
 
 	"azul3d.org/engine/gfx"
 	"azul3d.org/engine/gfx/camera"
@@ -18,58 +21,222 @@ import (
 	"azul3d.org/engine/keyboard"
 	"azul3d.org/engine/lmath"
 	"azul3d.org/engine/mouse"
-	"azul3d.org/engine/tmx"
 
 	"azul3d.org/examples/abs"
+	"azul3d.org/examples/camera2d"
+	"azul3d.org/examples/tiled"
+	"azul3d.org/examples/tiledgfx"
+	"azul3d.org/examples/timing"
 )
 
 // setOrthoScale sets the camera's projection matrix to an orthographic one
 // using the given viewing rectangle. It performs scaling with the viewing
-// rectangle.
+// rectangle. The size is not rounded, such that at the pixel-perfect zooms of
+// camera2d map pixels line up with screen pixels.
 func setOrthoScale(c *camera.Camera, view image.Rectangle, scale float64) {
-	w := float64(c.View.Dx())
-	w *= scale
-	w = float64(int((w / 2.0)))
-
-	h := float64(c.View.Dy())
-	h *= scale
-	h = float64(int((h / 2.0)))
-
+	w, h := orthoSize(c, scale)
 	m := lmath.Mat4Ortho(-w, w, -h, h, c.Near, c.Far)
 	c.P = gfx.ConvertMat4(m)
 }
 
+// orthoSize returns half the width and height in pixels on the map of what
+// the camera sees with the projection set by setOrthoScale.
+func orthoSize(c *camera.Camera, scale float64) (w, h float64) {
+	w = float64(c.View.Dx()) * scale / 2
+	h = float64(c.View.Dy()) * scale / 2
+	return w, h
+}
+
+// viewRect returns the rectangle in pixels on the map that the camera sees
+// with the projection set by setOrthoScale.
+func viewRect(c *camera.Camera, scale float64) image.Rectangle {
+	w, h := orthoSize(c, scale)
+	p := c.Pos()
+	return image.Rect(
+		int(math.Floor(p.X-w)), int(math.Floor(-p.Z-h)),
+		int(math.Ceil(p.X+w)), int(math.Ceil(-p.Z+h)),
+	)
+}
+
+// screenToMap returns the position in pixels on the map under the position
+// x, y in pixels on the screen, with the projection set by setOrthoScale.
+func screenToMap(c *camera.Camera, scale float64, x, y float64) tiled.Vec2 {
+	w, h := orthoSize(c, scale)
+	p := c.Pos()
+	return tiled.Vec2{
+		X: p.X - w + 2*w*x/float64(c.View.Dx()),
+		Y: -p.Z - h + 2*h*y/float64(c.View.Dy()),
+	}
+}
+
 // gfxLoop is responsible for drawing things to the window.
 func gfxLoop(w window.Window, d gfx.Device) {
-	// Create a new orthographic (2D) camera.
+	// Create a new orthographic (2D) camera, moved by a controller that
+	// keeps it over the map.
 	cam := camera.NewOrtho(d.Bounds())
-	camZoom := 1.0       // 1x zoom
-	camZoomSpeed := 0.01 // 0.01x zoom for each scroll wheel click.
-	camMinZoom := 0.1
+	ctl := camera2d.New(lmath.Vec2{X: float64(cam.View.Dx()), Y: float64(cam.View.Dy())})
+
+	// camScale returns the size in pixels on the map of a screen pixel.
+	camScale := func() float64 { return 1 / ctl.Zoom }
 
-	// updateCamera simply calls setOrthoScale with the values above.
+	// updateCamera sets the projection for the zoom and size of the view.
 	updateCamera := func() {
-		if camZoom < camMinZoom {
-			camZoom = camMinZoom
-		}
-		setOrthoScale(cam, d.Bounds(), camZoom)
+		ctl.Size = lmath.Vec2{X: float64(cam.View.Dx()), Y: float64(cam.View.Dy())}
+		ctl.Clamp()
+		setOrthoScale(cam, d.Bounds(), camScale())
+	}
+
+	// placeCamera moves the camera to the controller's position, two units
+	// back from the map.
+	placeCamera := func() {
+		p := ctl.Snapped()
+		cam.SetPos(lmath.Vec3{p.X, -2, -p.Y})
 	}
 
 	// Update the camera now.
 	updateCamera()
+	placeCamera()
+
+	// Load the TMX map file, along with its tilesets.
+	fsys, name := abs.FS(), "azul3d_tmx/data/test_base64.tmx"
+	if len(*mapFile) > 0 {
+		var err error
+		if fsys, name, err = abs.DiskFS(*mapFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	tmxMap, err := tiled.Open(fsys, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *printObj {
+		printObjects(tmxMap)
+	}
 
-	// Move the camera back two units away from the card.
-	cam.SetPos(lmath.Vec3{0, -2, 0})
+	// Keep the view on the map, starting at its top-left corner.
+	ctl.Bounds = tmxMap.Bounds()
+	resetCamera := func() {
+		ctl.Pos = lmath.Vec2{X: float64(ctl.Bounds.Min.X), Y: float64(ctl.Bounds.Min.Y)}
+		ctl.Vel = lmath.Vec2{}
+		ctl.Clamp()
+		placeCamera()
+	}
+	resetCamera()
+	var scrolled float64 // Scrolling not yet zoomed by, less than a step.
 
-	// Load TMX map file.
-	tmxMap, layers, err := tmx.LoadFile(*mapFile, nil)
+	// Create the objects drawing each layer, from the tileset images or an
+	// atlas of them.
+	textures := &tiledgfx.Textures{FS: fsys}
+	shaders := &tiledgfx.Shaders{}
+	layers, err := tiledgfx.NewLayers(tmxMap, textures, shaders)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *useAtlas {
+		if textures.Atlas, err = tiledgfx.NewAtlas(tmxMap, textures); err != nil {
+			log.Fatal(err)
+		}
+		plain := tiledgfx.CountDraws(layers)
+		if layers, err = tiledgfx.NewLayers(tmxMap, textures, shaders); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(textures.Atlas)
+		fmt.Printf("Drawing the whole map takes %d draws instead of %d.\n", tiledgfx.CountDraws(layers), plain)
+	}
+	objMode := tiledgfx.ObjectsAll
+
+	// The tile under the cursor is outlined and shown in the window title
+	// while the cursor is not grabbed, and described when right clicking.
+	highlightShader, err := shaders.Open(tiledgfx.ShapeShader, gfx.Color{R: 1, G: 1, B: 1, A: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	hover := newHighlight(highlightShader)
+	var (
+		cursor     lmath.Vec2
+		haveCursor bool
+		grabbed    bool
+		title      string
+	)
+
+	// In edit mode the tiles of a layer are painted, erased or filled by
+	// clicking and dragging instead.
+	ed := newEditor(tmxMap, layers)
+	editing := false
+
+	// camCenter returns the position in pixels on the map at the center of
+	// the view.
+	camCenter := func() tiled.Vec2 {
+		p := cam.Pos()
+		return tiled.Vec2{X: p.X, Y: -p.Z}
+	}
+
+	// editCell returns the cell of the layer being edited under the cursor,
+	// and the offset the layer is drawn at.
+	editCell := func() (image.Point, tiled.Vec2) {
+		p := screenToMap(cam, camScale(), cursor.X, cursor.Y)
+		shift := ed.shift(camCenter())
+		return tmxMap.TileAt(tiled.Vec2{X: p.X - shift.X, Y: p.Y - shift.Y}), shift
+	}
+
+	// editKey handles a key typed in edit mode, returning false if it is not
+	// one of the editing keys.
+	editKey := func(s string) bool {
+		var err error
+		switch s {
+		case "b":
+			ed.tool = paintTool
+		case "x":
+			ed.tool = eraseTool
+		case "f":
+			ed.tool = fillTool
+		case "[":
+			ed.nextBrush(-1)
+		case "]":
+			ed.nextBrush(1)
+		case "p":
+			cell, _ := editCell()
+			ed.pickBrush(cell)
+		case "l":
+			ed.nextLayer()
+		case "z":
+			err = ed.step(&ed.undo, &ed.redo)
+		case "y":
+			err = ed.step(&ed.redo, &ed.undo)
+		case "s":
+			path := *savePath
+			if len(path) == 0 {
+				path = *mapFile
+			}
+			if len(path) == 0 {
+				fmt.Println("The example map is not saved over, use -save to choose a file.")
+				break
+			}
+			if err = ed.save(path, src); err == nil {
+				fmt.Println("Saved", path)
+			}
+		default:
+			return false
+		}
+		if err != nil {
+			log.Println(err)
+		}
+		return true
+	}
+
+	// Tile animations follow the frame clock, by which statistics are also
+	// printed.
+	clock := timing.For(d)
+	statsTicker := timing.NewTicker(clock, time.Second)
 
 	// Create an event mask for the events we are interested in.
 	evMask := window.FramebufferResizedEvents
 	evMask |= window.CursorMovedEvents
+	evMask |= window.CursorExitEvents
 	evMask |= window.MouseEvents
 	evMask |= window.MouseScrolledEvents
 	evMask |= window.KeyboardTypedEvents
@@ -87,26 +254,75 @@ func gfxLoop(w window.Window, d gfx.Device) {
 			updateCamera()
 
 		case mouse.ButtonEvent:
+			if editing && ev.Button == mouse.Left {
+				// Paint, erase or fill, dragging until the button is up. The
+				// button may be released outside of the window, which still
+				// ends the edit.
+				if ev.State != mouse.Down {
+					ed.end()
+				} else if haveCursor {
+					cell, _ := editCell()
+					if err := ed.begin(cell); err != nil {
+						log.Println(err)
+					}
+				}
+				break
+			}
 			if ev.Button == mouse.Left && ev.State == mouse.Up {
 				// Toggle mouse grab.
 				props := w.Props()
-				props.SetCursorGrabbed(!props.CursorGrabbed())
+				grabbed = !props.CursorGrabbed()
+				props.SetCursorGrabbed(grabbed)
 				w.Request(props)
 			}
+			if ev.Button == mouse.Right && ev.State == mouse.Up && haveCursor && !grabbed {
+				// Describe the tiles under the cursor.
+				p := screenToMap(cam, camScale(), cursor.X, cursor.Y)
+				hits := pick(tmxMap, layers, camCenter(), p)
+				if len(hits) == 0 {
+					t := tmxMap.TileAt(p)
+					fmt.Printf("No tile at %d,%d\n", t.X, t.Y)
+				}
+				for _, h := range hits {
+					fmt.Print(h.describe(tmxMap))
+				}
+			}
 
 		case mouse.Scrolled:
-			// Zoom and update the camera.
-			camZoom -= ev.Y * camZoomSpeed
+			// Zoom by a step for each scroll wheel click, at the cursor if
+			// it is free, and update the camera.
+			scrolled += ev.Y
+			steps := int(scrolled)
+			scrolled -= float64(steps)
+			at := ctl.Size.MulScalar(0.5)
+			if haveCursor && !grabbed {
+				at = cursor
+			}
+			ctl.ZoomAt(steps, at)
 			updateCamera()
+			placeCamera()
+
+		case window.CursorExit:
+			haveCursor = false
 
 		case window.CursorMoved:
 			if ev.Delta {
-				p := lmath.Vec3{ev.X, 0, -ev.Y}
-				p = p.MulScalar(camZoom)
-				cam.SetPos(cam.Pos().Add(p))
+				ctl.Move(lmath.Vec2{X: ev.X, Y: ev.Y})
+				placeCamera()
+				break
+			}
+			cursor, haveCursor = lmath.Vec2{X: ev.X, Y: ev.Y}, true
+			if editing {
+				cell, _ := editCell()
+				if err := ed.drag(cell); err != nil {
+					log.Println(err)
+				}
 			}
 
 		case keyboard.Typed:
+			if editing && editKey(ev.S) {
+				break
+			}
 			switch ev.S {
 			case "m":
 				// Toggle MSAA now.
@@ -114,7 +330,22 @@ func gfxLoop(w window.Window, d gfx.Device) {
 				d.SetMSAA(msaa)
 				fmt.Println("MSAA Enabled?", msaa)
 			case "r":
-				cam.SetPos(lmath.Vec3{0, -2, 0})
+				resetCamera()
+			case "o":
+				// Cycle through what is drawn of the object layers.
+				objMode = (objMode + 1) % (tiledgfx.ObjectsHidden + 1)
+				fmt.Println("Objects:", objMode)
+			case "e":
+				// Toggle edit mode, releasing the cursor to paint with it.
+				editing = !editing
+				ed.end()
+				if editing && grabbed {
+					grabbed = false
+					props := w.Props()
+					props.SetCursorGrabbed(false)
+					w.Request(props)
+				}
+				fmt.Println("Editing?", editing)
 			}
 		}
 	}
@@ -123,18 +354,70 @@ func gfxLoop(w window.Window, d gfx.Device) {
 		// Handle events.
 		window.Poll(events, handleEvent)
 
+		// Pan with the arrow keys, and the cursor at the edges of the window
+		// while it is free.
+		kb := w.Keyboard()
+		var in camera2d.Input
+		if kb.Down(keyboard.ArrowLeft) {
+			in.Keys.X--
+		}
+		if kb.Down(keyboard.ArrowRight) {
+			in.Keys.X++
+		}
+		if kb.Down(keyboard.ArrowUp) {
+			in.Keys.Y--
+		}
+		if kb.Down(keyboard.ArrowDown) {
+			in.Keys.Y++
+		}
+		in.Cursor, in.HasCursor = cursor, haveCursor && !grabbed
+		ctl.Update(clock.Dt(), in)
+		placeCamera()
+
 		// Clear color and depth buffers.
 		d.Clear(d.Bounds(), gfx.Color{1, 1, 1, 1})
 		d.ClearDepth(d.Bounds(), 1.0)
 
-		// Draw the TMX map to the screen.
-		for _, layer := range tmxMap.Layers {
-			objects, ok := layers[layer.Name]
-			if ok {
-				for _, obj := range objects {
-					d.Draw(d.Bounds(), obj, cam)
-				}
+		// Draw the TMX map to the screen, advancing animated tiles by the
+		// frame clock.
+		// Only chunks within the camera's view are drawn.
+		var stats tiledgfx.DrawStats
+		view := viewRect(cam, camScale())
+		center := camCenter()
+		elapsed := clock.Time()
+		for _, l := range layers {
+			l.Draw(d, cam, center, view, elapsed, objMode, &stats)
+		}
+		if *printStats && statsTicker.Ticked() {
+			fmt.Println(stats)
+		}
+
+		// Outline the tile under the cursor, or its cell if there is none,
+		// and show it in the window title.
+		if haveCursor && !grabbed {
+			p := screenToMap(cam, camScale(), cursor.X, cursor.Y)
+			hits := pick(tmxMap, layers, center, p)
+			var t string
+			if editing {
+				// Outline the cell of the layer being edited instead.
+				cell, shift := editCell()
+				hover.set(tmxMap, cell, shift)
+				t = fmt.Sprintf("%s %d,%d", ed.status(), cell.X, cell.Y)
+			} else if len(hits) > 0 {
+				hover.set(tmxMap, hits[0].pos, hits[0].shift)
+				t = hits[0].summary(tmxMap)
+			} else {
+				cell := tmxMap.TileAt(p)
+				hover.set(tmxMap, cell, tiled.Vec2{})
+				t = fmt.Sprintf("%d,%d", cell.X, cell.Y)
+			}
+			if t != title {
+				title = t
+				props := w.Props()
+				props.SetTitle("azul3d_tmx {FPS} - " + title)
+				w.Request(props)
 			}
+			hover.draw(d, cam)
 		}
 
 		// Render the whole frame.
@@ -142,15 +425,26 @@ func gfxLoop(w window.Window, d gfx.Device) {
 	}
 }
 
-var (
-	defaultMapFile = abs.Path("azul3d_tmx/data/test_base64.tmx")
-	mapFile        = flag.String("file", defaultMapFile, "tmx map file to load")
-)
+// mapFile is the TMX map file to load, an empty string loads the example map.
+var mapFile = flag.String("file", "", "tmx map file to load (default: the example map)")
 
-func init() {
-	flag.Parse()
-}
+// savePath is the file that edits of the map are saved to, an empty string
+// saving over the map file.
+var savePath = flag.String("save", "", "tmx file to save edits of the map to (default: the -file map)")
+
+// useAtlas makes the example pack the tiles of every tileset into atlas
+// textures, such that fewer draws are needed.
+var useAtlas = flag.Bool("atlas", false, "pack the tilesets into atlas textures and print how many draws it saves")
+
+// printStats makes the example print how many chunks of the map were drawn
+// each second.
+var printStats = flag.Bool("stats", false, "print how many chunks of the map are drawn each second")
+
+// printObj makes the example print the objects of the map and their properties.
+var printObj = flag.Bool("objects", false, "print the objects of the map and their properties")
 
 func main() {
+	flag.Parse()
+
 	window.Run(gfxLoop, nil)
 }
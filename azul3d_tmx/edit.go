@@ -0,0 +1,261 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+
+	"azul3d.org/examples/tiled"
+	"azul3d.org/examples/tiledgfx"
+)
+
+// tool is what clicking on the map does in edit mode.
+type tool int
+
+const (
+	paintTool tool = iota // Set tiles to the brush, dragging to paint more.
+	eraseTool             // Remove tiles, dragging to erase more.
+	fillTool              // Set the connected tiles that are the same to the brush.
+)
+
+// String returns the name of the tool.
+func (t tool) String() string {
+	switch t {
+	case paintTool:
+		return "paint"
+	case eraseTool:
+		return "erase"
+	default:
+		return "fill"
+	}
+}
+
+// change is a tile changed by an edit.
+type change struct {
+	pos      image.Point
+	old, new tiled.GID
+}
+
+// edit is what can be undone at once: a stroke of the brush or eraser, or a
+// fill.
+type edit struct {
+	layer   *tiled.TileLayer
+	changes []change
+}
+
+// positions returns the positions of the changed tiles.
+func (e *edit) positions() []image.Point {
+	pos := make([]image.Point, len(e.changes))
+	for i, c := range e.changes {
+		pos[i] = c.pos
+	}
+	return pos
+}
+
+// editor edits the tile layers of a map, redrawing them as they change.
+type editor struct {
+	m      *tiled.Map
+	layers []*tiled.TileLayer // The tile layers of the map, bottom up.
+	gids   []tiled.GID        // Every tile of the map's tilesets.
+	active int                // The index of the layer being edited.
+	tool   tool
+	brush  int       // The index in gids of the tile painted.
+	flip   tiled.GID // The flip flags of the tile painted.
+	stroke *edit     // The edit being made while the mouse is down.
+	undo   []*edit
+	redo   []*edit
+
+	// drawn holds the layers drawing the tile layers that are visible. Hidden
+	// layers are edited all the same, but there is nothing to redraw.
+	drawn map[*tiled.TileLayer]*tiledgfx.Layer
+}
+
+// newEditor returns an editor of every tile layer of the map, hidden or not,
+// redrawing those among the layers drawing it. The top one is edited first.
+func newEditor(m *tiled.Map, layers []*tiledgfx.Layer) *editor {
+	e := &editor{m: m, layers: m.TileLayers, drawn: make(map[*tiled.TileLayer]*tiledgfx.Layer)}
+	for _, l := range layers {
+		if tl, ok := l.Source.(*tiled.TileLayer); ok {
+			e.drawn[tl] = l
+		}
+	}
+	e.active = len(e.layers) - 1
+	for _, ts := range m.Tilesets {
+		for id := 0; id < ts.TileCount; id++ {
+			e.gids = append(e.gids, tiled.GID(ts.FirstGID+uint32(id)))
+		}
+	}
+	return e
+}
+
+// layer returns the layer being edited, or nil if there is none.
+func (e *editor) layer() *tiled.TileLayer {
+	if e.active < 0 {
+		return nil
+	}
+	return e.layers[e.active]
+}
+
+// nextLayer switches to editing the next layer, wrapping around to the bottom
+// one after the top one.
+func (e *editor) nextLayer() {
+	if len(e.layers) > 0 {
+		e.active = (e.active + 1) % len(e.layers)
+	}
+}
+
+// brushGID returns the tile painted with its flip flags, zero if the map has
+// no tiles.
+func (e *editor) brushGID() tiled.GID {
+	if len(e.gids) == 0 {
+		return 0
+	}
+	return e.gids[e.brush] | e.flip
+}
+
+// shift returns the offset that the layer being edited is drawn at, with the
+// center of the view at the given position.
+func (e *editor) shift(center tiled.Vec2) tiled.Vec2 {
+	l := e.layer()
+	if l == nil {
+		return tiled.Vec2{}
+	}
+	return l.Info().Composite().Shift(e.m.ParallaxOrigin, center)
+}
+
+// update redraws the tiles at the positions of the layer, if it is drawn.
+func (e *editor) update(l *tiled.TileLayer, tiles []image.Point) error {
+	if ml, ok := e.drawn[l]; ok {
+		return ml.UpdateTiles(tiles)
+	}
+	return nil
+}
+
+// nextBrush switches the brush to the tile n tiles after the current one,
+// across all tilesets.
+func (e *editor) nextBrush(n int) {
+	if len(e.gids) > 0 {
+		e.brush = ((e.brush+n)%len(e.gids) + len(e.gids)) % len(e.gids)
+		e.flip = 0
+	}
+}
+
+// pickBrush switches the brush to the tile at pos of the layer being edited,
+// if there is one.
+func (e *editor) pickBrush(pos image.Point) {
+	l := e.layer()
+	if l == nil {
+		return
+	}
+	gid := l.At(pos.X, pos.Y)
+	for i, g := range e.gids {
+		if uint32(g) == gid.ID() {
+			e.brush, e.flip = i, gid.Flip()
+		}
+	}
+}
+
+// status returns a description of the state of the editor, short enough for
+// the window title.
+func (e *editor) status() string {
+	l := e.layer()
+	if l == nil {
+		return "edit: no tile layer"
+	}
+	s := fmt.Sprintf("edit: %v", e.tool)
+	if e.tool != eraseTool {
+		s += fmt.Sprintf(" GID %d", e.brushGID().ID())
+		if e.flip != 0 {
+			s += " (" + flipNames(e.flip) + ")"
+		}
+	}
+	s += fmt.Sprintf(" on %q", l.Name)
+	if _, ok := e.drawn[l]; !ok {
+		s += " (hidden)"
+	}
+	return s
+}
+
+// begin starts an edit with the tool at pos, which is continued by calling
+// drag as the mouse moves, until end is called.
+func (e *editor) begin(pos image.Point) error {
+	l := e.layer()
+	if l == nil {
+		return nil
+	}
+	e.stroke = &edit{layer: l}
+	if e.tool != fillTool {
+		return e.drag(pos)
+	}
+	old := l.At(pos.X, pos.Y)
+	for _, p := range l.Fill(pos.X, pos.Y, e.brushGID()) {
+		e.stroke.changes = append(e.stroke.changes, change{p, old, e.brushGID()})
+	}
+	return e.update(l, e.stroke.positions())
+}
+
+// drag continues the edit being made at pos.
+func (e *editor) drag(pos image.Point) error {
+	if e.stroke == nil || e.tool == fillTool {
+		return nil
+	}
+	gid := e.brushGID()
+	if e.tool == eraseTool {
+		gid = 0
+	}
+	tl := e.stroke.layer
+	old := tl.At(pos.X, pos.Y)
+	if old == gid || !tl.Set(pos.X, pos.Y, gid) {
+		return nil
+	}
+	e.stroke.changes = append(e.stroke.changes, change{pos, old, gid})
+	return e.update(tl, []image.Point{pos})
+}
+
+// end ends the edit being made, such that it can be undone.
+func (e *editor) end() {
+	if e.stroke != nil && len(e.stroke.changes) > 0 {
+		e.undo = append(e.undo, e.stroke)
+		e.redo = nil
+	}
+	e.stroke = nil
+}
+
+// step undoes the last edit of from, or redoes it if from holds undone edits,
+// and moves it to to.
+func (e *editor) step(from, to *[]*edit) error {
+	if len(*from) == 0 || e.stroke != nil {
+		return nil
+	}
+	ed := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, ed)
+
+	tl := ed.layer
+	undo := from == &e.undo
+	for i := range ed.changes {
+		// Undo in reverse, as a stroke may change a tile more than once.
+		if undo {
+			c := ed.changes[len(ed.changes)-1-i]
+			tl.Set(c.pos.X, c.pos.Y, c.old)
+		} else {
+			c := ed.changes[i]
+			tl.Set(c.pos.X, c.pos.Y, c.new)
+		}
+	}
+	return e.update(tl, ed.positions())
+}
+
+// save writes the map to the file at path as the TMX file src, which the map
+// was read from, with the tiles as they are now.
+func (e *editor) save(path string, src []byte) error {
+	out, err := e.m.Rewrite(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
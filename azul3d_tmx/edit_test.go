@@ -0,0 +1,116 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"azul3d.org/examples/tiled"
+	"azul3d.org/examples/tiledgfx"
+)
+
+// newTestLayer returns a 4x4 tile layer filled with the tile.
+func newTestLayer(name string, visible bool, gid tiled.GID) *tiled.TileLayer {
+	l := &tiled.TileLayer{
+		LayerInfo: tiled.LayerInfo{Name: name, Visible: visible, Opacity: 1},
+		Width:     4,
+		Height:    4,
+		Tiles:     make([]tiled.GID, 16),
+	}
+	for i := range l.Tiles {
+		l.Tiles[i] = gid
+	}
+	return l
+}
+
+func TestEditorHiddenLayers(t *testing.T) {
+	ground := newTestLayer("ground", true, 1)
+	hidden := newTestLayer("collision", false, 0)
+	m := &tiled.Map{
+		Orientation: tiled.Orthogonal,
+		Width:       4,
+		Height:      4,
+		TileWidth:   16,
+		TileHeight:  16,
+		Tilesets:    []*tiled.Tileset{{FirstGID: 1, Name: "tiles", TileCount: 4, Columns: 2}},
+		Layers:      []tiled.Layer{ground, hidden},
+		TileLayers:  []*tiled.TileLayer{ground, hidden},
+	}
+
+	// Only the visible layer is drawn, and so among the layers given.
+	e := newEditor(m, []*tiledgfx.Layer{{Source: ground}})
+	if len(e.layers) != 2 {
+		t.Fatalf("got %d editable layers, want 2", len(e.layers))
+	}
+	if e.layer() != hidden {
+		t.Fatalf("editing %q, want the top layer", e.layer().Name)
+	}
+	if s := e.status(); !strings.Contains(s, `"collision" (hidden)`) {
+		t.Errorf("status %q does not tell that the layer is hidden", s)
+	}
+
+	// Hidden layers are edited all the same.
+	e.nextBrush(2)
+	if err := e.begin(image.Pt(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.drag(image.Pt(2, 1)); err != nil {
+		t.Fatal(err)
+	}
+	e.end()
+	if hidden.At(1, 1) != 3 || hidden.At(2, 1) != 3 {
+		t.Errorf("painted %d and %d, want 3", hidden.At(1, 1), hidden.At(2, 1))
+	}
+	if err := e.step(&e.undo, &e.redo); err != nil {
+		t.Fatal(err)
+	}
+	if hidden.At(1, 1) != 0 || hidden.At(2, 1) != 0 {
+		t.Errorf("undo left %d and %d, want 0", hidden.At(1, 1), hidden.At(2, 1))
+	}
+
+	e.nextLayer()
+	if e.layer() != ground {
+		t.Errorf("editing %q after switching layers, want %q", e.layer().Name, ground.Name)
+	}
+}
+
+func TestEditorPickBrush(t *testing.T) {
+	l := newTestLayer("ground", false, 0)
+	l.Set(0, 0, 3|tiled.FlipHorizontal|tiled.FlipDiagonal)
+	l.Set(1, 0, 2)
+	m := &tiled.Map{
+		Tilesets:   []*tiled.Tileset{{FirstGID: 1, TileCount: 4}},
+		Layers:     []tiled.Layer{l},
+		TileLayers: []*tiled.TileLayer{l},
+	}
+	e := newEditor(m, nil)
+
+	// The picked tile is painted with its flip flags.
+	e.pickBrush(image.Pt(0, 0))
+	if got, want := e.brushGID(), 3|tiled.FlipHorizontal|tiled.FlipDiagonal; got != want {
+		t.Errorf("picked %#x, want %#x", uint32(got), uint32(want))
+	}
+	if err := e.begin(image.Pt(3, 3)); err != nil {
+		t.Fatal(err)
+	}
+	e.end()
+	if got, want := l.At(3, 3), 3|tiled.FlipHorizontal|tiled.FlipDiagonal; got != want {
+		t.Errorf("painted %#x, want %#x", uint32(got), uint32(want))
+	}
+
+	e.pickBrush(image.Pt(1, 0))
+	if got := e.brushGID(); got != 2 {
+		t.Errorf("picked %#x, want 2", uint32(got))
+	}
+
+	// Switching tiles starts with them unflipped.
+	e.pickBrush(image.Pt(0, 0))
+	e.nextBrush(1)
+	if got := e.brushGID(); got != 4 {
+		t.Errorf("next brush %#x, want 4", uint32(got))
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"azul3d.org/examples/golden"
+	"azul3d.org/examples/tiledgfx"
+)
+
+func TestGolden(t *testing.T) {
+	golden.Run(t, golden.Example{
+		GfxLoop: gfxLoop,
+		Shaders: tiledgfx.HeadlessShaders,
+		Images:  []string{"azul3d_tmx/data/tilesheet.png"},
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+
+	"azul3d.org/examples/tiled"
+	"azul3d.org/examples/tiledgfx"
+)
+
+// hit is a tile found under the cursor.
+type hit struct {
+	layer *tiled.TileLayer
+	shift tiled.Vec2  // The offset the layer is drawn at.
+	pos   image.Point // The position of the tile in the layer.
+	gid   tiled.GID
+}
+
+// pick returns the tiles of the layers at the position in pixels on the map,
+// from the top layer down, with the center of the view at the given position.
+// Each layer is offset and scrolled as it is drawn, so the tile is looked up
+// at the position as seen from the layer.
+func pick(m *tiled.Map, layers []*tiledgfx.Layer, center, p tiled.Vec2) []hit {
+	var hits []hit
+	for i := len(layers) - 1; i >= 0; i-- {
+		tl, ok := layers[i].Source.(*tiled.TileLayer)
+		if !ok {
+			continue
+		}
+		shift := layers[i].Shift(center)
+		pos := m.TileAt(tiled.Vec2{X: p.X - shift.X, Y: p.Y - shift.Y})
+		if gid := tl.At(pos.X, pos.Y); gid.ID() != 0 {
+			hits = append(hits, hit{tl, shift, pos, gid})
+		}
+	}
+	return hits
+}
+
+// flipNames returns the names of the flip flags of the GID.
+func flipNames(gid tiled.GID) string {
+	var flips []string
+	if gid&tiled.FlipHorizontal != 0 {
+		flips = append(flips, "horizontal")
+	}
+	if gid&tiled.FlipVertical != 0 {
+		flips = append(flips, "vertical")
+	}
+	if gid&tiled.FlipDiagonal != 0 {
+		flips = append(flips, "diagonal")
+	}
+	if gid&tiled.RotateHex120 != 0 {
+		flips = append(flips, "rotated 120")
+	}
+	if len(flips) == 0 {
+		return "none"
+	}
+	return strings.Join(flips, ", ")
+}
+
+// summary returns a one-line description of the hit, short enough for the
+// window title.
+func (h hit) summary(m *tiled.Map) string {
+	s := fmt.Sprintf("%q %d,%d GID %d", h.layer.Name, h.pos.X, h.pos.Y, h.gid.ID())
+	if ts, id := m.Tileset(h.gid); ts != nil {
+		s += fmt.Sprintf(" (%s #%d)", ts.Name, id)
+	}
+	return s
+}
+
+// describe returns a description of the hit tile, with its tileset, flip
+// flags and properties.
+func (h hit) describe(m *tiled.Map) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Layer %q, tile %d,%d\n", h.layer.Name, h.pos.X, h.pos.Y)
+	fmt.Fprintf(&b, "\tGID:     %d\n", h.gid.ID())
+	fmt.Fprintf(&b, "\tFlipped: %s\n", flipNames(h.gid))
+
+	ts, id := m.Tileset(h.gid)
+	if ts == nil {
+		fmt.Fprintf(&b, "\tTileset: none\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\tTileset: %q (first GID %d), tile %d\n", ts.Name, ts.FirstGID, id)
+	t, ok := ts.Tiles[id]
+	if !ok {
+		return b.String()
+	}
+	if len(t.Type) > 0 {
+		fmt.Fprintf(&b, "\tType:    %q\n", t.Type)
+	}
+	if len(t.Animation) > 0 {
+		fmt.Fprintf(&b, "\tFrames:  %d\n", len(t.Animation))
+	}
+	names := make([]string, 0, len(t.Properties))
+	for name := range t.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\t%s = %q\n", name, t.Properties[name])
+	}
+	return b.String()
+}
+
+// highlight outlines the cell of a tile on the map.
+type highlight struct {
+	obj   *gfx.Object
+	cell  image.Point
+	shift tiled.Vec2
+	shown bool
+}
+
+// newHighlight returns a new, hidden, highlight drawn with the shader.
+func newHighlight(shader *gfx.Shader) *highlight {
+	mesh := gfx.NewMesh()
+	mesh.Primitive = gfx.Lines
+	return &highlight{obj: tiledgfx.NewObject(shader, mesh)}
+}
+
+// set outlines the cell of the tile at the position on the map, offset by
+// shift, rebuilding the outline only if the cell changed.
+func (h *highlight) set(m *tiled.Map, cell image.Point, shift tiled.Vec2) {
+	if h.shown && cell == h.cell && shift == h.shift {
+		return
+	}
+	h.cell, h.shift, h.shown = cell, shift, true
+
+	color := gfx.Color{R: 1, G: 1, B: 0, A: 1}
+	mesh := gfx.NewMesh()
+	mesh.Primitive = gfx.Lines
+	points := m.CellOutline(cell.X, cell.Y)
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		mesh.Vertices = append(mesh.Vertices, tiledgfx.WorldPos(a), tiledgfx.WorldPos(b))
+		mesh.Colors = append(mesh.Colors, color, color)
+	}
+	h.obj.Lock()
+	h.obj.Meshes[0] = mesh
+	h.obj.Unlock()
+	h.obj.SetPos(lmath.Vec3{X: shift.X, Y: 0, Z: -shift.Y})
+}
+
+// draw draws the outline, if it is shown.
+func (h *highlight) draw(d gfx.Device, cam gfx.Camera) {
+	if h.shown {
+		d.Draw(d.Bounds(), h.obj, cam)
+	}
+}
+
+// printObjects prints every object of the map with its properties, as a game
+// would spawn entities from them.
+func printObjects(m *tiled.Map) {
+	for _, g := range m.ObjectGroups {
+		fmt.Printf("Object group %q (%d objects) %v\n", g.Name, len(g.Objects), g.Properties)
+		for _, o := range g.Objects {
+			var props []string
+			for k, v := range o.Properties {
+				props = append(props, fmt.Sprintf("%s=%q", k, v))
+			}
+			sort.Strings(props)
+			fmt.Printf("\t%d %q type %q: %v at %v,%v size %vx%v [%s]\n",
+				o.ID, o.Name, o.Type, o.Shape, o.X, o.Y, o.Width, o.Height, strings.Join(props, " "))
+		}
+	}
+}
@@ -0,0 +1,10 @@
+#version 120
+
+varying vec4 frontColor;
+
+uniform vec4 Tint;
+
+void main()
+{
+	gl_FragColor = frontColor * Tint;
+}
@@ -0,0 +1,12 @@
+#version 120
+
+varying vec4 frontColor;
+varying vec2 tc0;
+
+uniform sampler2D Texture0;
+uniform vec4 Tint;
+
+void main()
+{
+	gl_FragColor = frontColor * texture2D(Texture0, tc0) * Tint;
+}
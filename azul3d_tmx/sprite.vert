@@ -0,0 +1,17 @@
+#version 120
+
+attribute vec3 Vertex;
+attribute vec4 Color;
+attribute vec2 TexCoord0;
+
+uniform mat4 MVP;
+
+varying vec4 frontColor;
+varying vec2 tc0;
+
+void main()
+{
+	frontColor = Color;
+	tc0 = TexCoord0;
+	gl_Position = MVP * vec4(Vertex, 1.0);
+}
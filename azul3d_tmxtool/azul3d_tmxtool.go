@@ -0,0 +1,168 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// tmxtool is a tool which renders TMX maps to PNG images and validates them,
+// without opening a window, for use in content pipelines.
+//
+// Usage:
+//
+//	azul3d_tmxtool png [-o out.png] [-scale 1] [-rect x,y,w,h] map.tmx
+//	azul3d_tmxtool validate map.tmx...
+//
+// The png command renders the whole map, or the rectangle of it in pixels on
+// the map given with -rect, to a PNG file named after the map unless -o is
+// given. The map is drawn by the layer code of azul3d_tmx on a headless device,
+// so tile, image and tile object layers look as they do in the viewer; the
+// outlines of other objects are not drawn.
+//
+// The validate command reports the problems of each map, and exits with one
+// of the status codes below.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"azul3d.org/examples/abs"
+	"azul3d.org/examples/tiled"
+)
+
+// The exit status codes of the tool.
+const (
+	exitOK          = 0 // Success, and no problems were found.
+	exitFailed      = 1 // Rendering failed, or a map has errors.
+	exitUsage       = 2 // The command line is invalid.
+	exitUnsupported = 3 // The maps have no errors, but use unsupported features.
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "\tazul3d_tmxtool png [-o out.png] [-scale 1] [-rect x,y,w,h] map.tmx")
+	fmt.Fprintln(os.Stderr, "\tazul3d_tmxtool validate map.tmx...")
+	os.Exit(exitUsage)
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "png":
+		os.Exit(pngCommand(os.Args[2:]))
+	case "validate":
+		os.Exit(validateCommand(os.Args[2:]))
+	default:
+		usage()
+	}
+}
+
+// pngCommand runs the png command with the arguments following it.
+func pngCommand(args []string) int {
+	flags := flag.NewFlagSet("png", flag.ExitOnError)
+	out := flags.String("o", "", "PNG file to write (default: the map's name with a .png extension)")
+	scale := flags.Float64("scale", 1, "scale to render the map at")
+	rect := flags.String("rect", "", "rectangle of the map to render as x,y,w,h in pixels (default: the whole map)")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *scale <= 0 {
+		usage()
+	}
+	path := flags.Arg(0)
+
+	fsys, name, err := abs.DiskFS(path)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	m, err := tiled.Open(fsys, name)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	area := m.Bounds()
+	if len(*rect) > 0 {
+		if area, err = parseRect(*rect); err != nil {
+			log.Println(err)
+			return exitUsage
+		}
+	}
+
+	img, err := render(m, fsys, area, *scale)
+	if err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	if len(*out) == 0 {
+		*out = strings.TrimSuffix(path, filepath.Ext(path)) + ".png"
+	}
+	if err := writePNG(*out, img); err != nil {
+		log.Println(err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// validateCommand runs the validate command with the arguments following it.
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usage()
+	}
+
+	status := exitOK
+	for _, path := range flags.Args() {
+		problems := validate(path)
+		for _, p := range problems {
+			fmt.Printf("%s: %v\n", path, p)
+			switch {
+			case p.unsupported && status == exitOK:
+				status = exitUnsupported
+			case !p.unsupported:
+				status = exitFailed
+			}
+		}
+	}
+	return status
+}
+
+// parseRect parses a rectangle written as "x,y,w,h".
+func parseRect(s string) (image.Rectangle, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 4 {
+		return image.Rectangle{}, fmt.Errorf("invalid rectangle %q", s)
+	}
+	var v [4]int
+	for i, f := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("invalid rectangle %q", s)
+		}
+		v[i] = n
+	}
+	if v[2] <= 0 || v[3] <= 0 {
+		return image.Rectangle{}, fmt.Errorf("empty rectangle %q", s)
+	}
+	return image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]), nil
+}
+
+// writePNG writes the image to the PNG file at path.
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
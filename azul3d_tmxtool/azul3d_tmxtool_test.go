@@ -0,0 +1,231 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRect(t *testing.T) {
+	tests := []struct {
+		s    string
+		want image.Rectangle
+		err  bool
+	}{
+		{"0,0,10,20", image.Rect(0, 0, 10, 20), false},
+		{"-32, 16, 64, 8", image.Rect(-32, 16, 32, 24), false},
+		{"1,2,3", image.Rectangle{}, true},
+		{"1,2,3,4,5", image.Rectangle{}, true},
+		{"a,2,3,4", image.Rectangle{}, true},
+		{"1.5,2,3,4", image.Rectangle{}, true},
+		{"0,0,0,10", image.Rectangle{}, true},
+		{"0,0,10,-1", image.Rectangle{}, true},
+		{"", image.Rectangle{}, true},
+	}
+	for _, tst := range tests {
+		got, err := parseRect(tst.s)
+		if (err != nil) != tst.err {
+			t.Errorf("parseRect(%q) error %v, want error %v", tst.s, err, tst.err)
+		}
+		if got != tst.want {
+			t.Errorf("parseRect(%q) = %v, want %v", tst.s, got, tst.want)
+		}
+	}
+}
+
+// dataDir is the directory of the test maps of azul3d_tmx.
+const dataDir = "../azul3d_tmx/data"
+
+// pngFile returns a PNG image of the given size.
+func pngFile(w, h int) string {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
+
+// tilesheet is an image the size of that of tilesheet.tsx, which is stored
+// with Git LFS and may not be checked out.
+var tilesheet = pngFile(288, 96)
+
+// writeMap copies the test map at the path within dataDir, tilesheet.tsx and
+// an image for it into a temporary directory, and returns the path of the map
+// there. The map is edited by the function if it is not nil, and files holds
+// files to write in place of the others by their path within dataDir, or to
+// leave out if empty.
+func writeMap(t *testing.T, name string, edit func(string) string, files map[string]string) string {
+	t.Helper()
+	all := map[string]string{"tilesheet.png": tilesheet}
+	for _, f := range []string{name, "tilesheet.tsx"} {
+		data, err := os.ReadFile(filepath.Join(dataDir, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		all[f] = string(data)
+	}
+	if edit != nil {
+		all[name] = edit(all[name])
+	}
+	for f, data := range files {
+		all[f] = data
+	}
+
+	dir := t.TempDir()
+	for f, data := range all {
+		if len(data) == 0 {
+			continue
+		}
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, name)
+}
+
+// badGID replaces a tile of the ground layer of encodings/csv.tmx, at 6,3,
+// with one beyond the tileset.
+func badGID(src string) string {
+	return strings.Replace(src, "1,2,2,3", "1,2,99,3", 1)
+}
+
+// repeatImage adds a repeated image layer to a map.
+func repeatImage(src string) string {
+	return strings.Replace(src, "</map>", `<imagelayer id="9" name="sky" repeatx="1"><image source="../tilesheet.png"/></imagelayer>
+</map>`, 1)
+}
+
+var validateTests = []struct {
+	name  string
+	edit  func(string) string
+	files map[string]string
+	want  []string
+}{
+	{name: "encodings/csv.tmx"},
+	{name: "encodings/base64.tmx"},
+	{name: "encodings/gzip_infinite.tmx"},
+	{name: "encodings/zlib.tmx"},
+	{name: "encodings/xml_infinite.tmx"},
+	{name: "orientations/isometric.tmx"},
+	{name: "orientations/staggered.tmx"},
+	{name: "orientations/hexagonal.tmx"},
+	{
+		name:  "encodings/csv.tmx",
+		files: map[string]string{"tilesheet.tsx": ""},
+		want:  []string{`error: missing external tileset "../tilesheet.tsx"`},
+	},
+	{
+		name: "encodings/csv.tmx",
+		edit: badGID,
+		want: []string{`error: layer "ground" has 1 tiles with out-of-range GIDs, the first GID 99 at 6,3`},
+	},
+	{
+		name:  "orientations/hexagonal.tmx",
+		files: map[string]string{"tilesheet.png": pngFile(320, 96)},
+		want:  []string{`error: ../tilesheet.tsx: image "tilesheet.png" is 320x96, but 288x96 is written`},
+	},
+	{
+		name:  "encodings/csv.tmx",
+		files: map[string]string{"tilesheet.png": "not a PNG"},
+		want:  []string{`error: ../tilesheet.tsx: unreadable image "tilesheet.png": image: unknown format`},
+	},
+	{
+		// Compressed layers that cannot be decoded are not also errors.
+		name: "encodings/zstd.tmx",
+		want: []string{"unsupported: zstd compression of layers is not supported"},
+	},
+	{
+		name: "encodings/zstd_infinite.tmx",
+		want: []string{"unsupported: zstd compression of layers is not supported"},
+	},
+	{
+		name: "encodings/xml.tmx",
+		edit: func(src string) string { return repeatImage(repeatImage(src)) },
+		want: []string{"unsupported: repeated image layers are drawn once (2 times)"},
+	},
+	{
+		name: "encodings/csv.tmx",
+		edit: func(src string) string {
+			return strings.Replace(src, `tilewidth="32"`, `tilewidth="16"`, 1)
+		},
+		want: []string{`unsupported: ../tilesheet.tsx: tileset "tilesheet" has 32x32 tiles, which do not fit the 16x32 cells of the map`},
+	},
+	{
+		name: "encodings/csv.tmx",
+		edit: func(src string) string { return badGID(repeatImage(src)) },
+		want: []string{
+			"unsupported: repeated image layers are drawn once",
+			`error: layer "ground" has 1 tiles with out-of-range GIDs, the first GID 99 at 6,3`,
+		},
+	},
+}
+
+func TestValidate(t *testing.T) {
+	for _, tst := range validateTests {
+		var got []string
+		for _, p := range validate(writeMap(t, tst.name, tst.edit, tst.files)) {
+			got = append(got, p.String())
+		}
+		if !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("%s: got problems\n\t%s\nwant\n\t%s", tst.name, strings.Join(got, "\n\t"), strings.Join(tst.want, "\n\t"))
+		}
+	}
+}
+
+// TestValidateExit checks that errors in any map take precedence over
+// unsupported features in the exit status.
+func TestValidateExit(t *testing.T) {
+	var (
+		ok          = writeMap(t, "encodings/csv.tmx", nil, nil)
+		unsupported = writeMap(t, "encodings/zstd.tmx", nil, nil)
+		failed      = writeMap(t, "encodings/csv.tmx", badGID, nil)
+	)
+	tests := []struct {
+		paths []string
+		want  int
+	}{
+		{[]string{ok}, exitOK},
+		{[]string{ok, ok}, exitOK},
+		{[]string{unsupported}, exitUnsupported},
+		{[]string{ok, unsupported, ok}, exitUnsupported},
+		{[]string{failed}, exitFailed},
+		{[]string{unsupported, failed}, exitFailed},
+		{[]string{failed, unsupported}, exitFailed},
+		{[]string{ok, failed, ok}, exitFailed},
+	}
+
+	// The problems are printed, which the test does not need.
+	stdout := os.Stdout
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	os.Stdout = null
+	defer func() { os.Stdout = stdout }()
+
+	for _, tst := range tests {
+		if got := validateCommand(tst.paths); got != tst.want {
+			t.Errorf("%d maps: exit status %d, want %d", len(tst.paths), got, tst.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"image"
+	_ "image/gif" // Add GIF and JPEG decoders for tileset images.
+	_ "image/jpeg"
+	"io/fs"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/camera"
+	"azul3d.org/engine/lmath"
+
+	"azul3d.org/examples/headless"
+	"azul3d.org/examples/tiled"
+	"azul3d.org/examples/tiledgfx"
+)
+
+// render renders the area of the map, in pixels on the map, at the scale. The
+// layers are drawn by a headless device exactly as azul3d_tmx draws them, and
+// scroll with parallax as if the center of the view was at the center of the
+// area. Animated tiles show their first frame.
+func render(m *tiled.Map, fsys fs.FS, area image.Rectangle, scale float64) (*image.RGBA, error) {
+	if area.Empty() {
+		return nil, errors.New("the map is empty")
+	}
+	w := int(math.Ceil(float64(area.Dx()) * scale))
+	h := int(math.Ceil(float64(area.Dy()) * scale))
+	d := headless.New(image.Rect(0, 0, w, h), gfx.Precision{})
+	d.Shaders = tiledgfx.HeadlessShaders
+	var frame *image.RGBA
+	d.OnRender = func(f *image.RGBA) { frame = f }
+
+	layers, err := tiledgfx.NewLayers(m, &tiledgfx.Textures{FS: fsys}, &tiledgfx.Shaders{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Look at the map from two units in front of it, with an orthographic
+	// projection sized such that the top-left corner of the image is that of
+	// the area.
+	hw, hh := float64(w)/scale/2, float64(h)/scale/2
+	center := tiled.Vec2{X: float64(area.Min.X) + hw, Y: float64(area.Min.Y) + hh}
+	cam := camera.NewOrtho(d.Bounds())
+	cam.SetPos(lmath.Vec3{X: center.X, Y: -2, Z: -center.Y})
+	cam.P = gfx.ConvertMat4(lmath.Mat4Ortho(-hw, hw, -hh, hh, cam.Near, cam.Far))
+	view := image.Rect(
+		int(math.Floor(center.X-hw)), int(math.Floor(center.Y-hh)),
+		int(math.Ceil(center.X+hw)), int(math.Ceil(center.Y+hh)),
+	)
+
+	var bg gfx.Color
+	if c := m.BackgroundColor; c.A != 0 {
+		bg = gfx.Color{R: float32(c.R) / 255, G: float32(c.G) / 255, B: float32(c.B) / 255, A: float32(c.A) / 255}
+	}
+	d.Clear(d.Bounds(), bg)
+	d.ClearDepth(d.Bounds(), 1.0)
+	var stats tiledgfx.DrawStats
+	for _, l := range layers {
+		l.Draw(d, cam, center, view, 0, tiledgfx.ObjectsSprites, &stats)
+	}
+	d.Render()
+	return frame, nil
+}
@@ -0,0 +1,360 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"path"
+
+	"azul3d.org/examples/abs"
+	"azul3d.org/examples/tiled"
+)
+
+// problem is a problem found with a map.
+type problem struct {
+	// file is the tileset file the problem is in, relative to the map, or
+	// empty if it is in the map file.
+	file string
+	msg  string
+
+	// unsupported is whether the problem is a feature that the tiled package
+	// or azul3d_tmx do not support, rather than an error.
+	unsupported bool
+}
+
+// String returns the problem as a line of text.
+func (p problem) String() string {
+	s := "error: "
+	if p.unsupported {
+		s = "unsupported: "
+	}
+	if len(p.file) > 0 {
+		s += p.file + ": "
+	}
+	return s + p.msg
+}
+
+// validator collects the problems of a map.
+type validator struct {
+	fsys     fs.FS
+	name     string // The name of the map file within fsys.
+	problems []problem
+	images   map[string]bool // The images checked already, by path.
+
+	// compression is whether layers are compressed with a method that the
+	// tiled package cannot decode.
+	compression bool
+}
+
+// validate returns the problems of the map file at path.
+func validate(path string) []problem {
+	fsys, name, err := abs.DiskFS(path)
+	if err != nil {
+		return []problem{{msg: err.Error()}}
+	}
+	v := &validator{fsys: fsys, name: name, images: make(map[string]bool)}
+	v.check()
+	return v.problems
+}
+
+// errorf records an error in the file.
+func (v *validator) errorf(file, format string, args ...interface{}) {
+	v.problems = append(v.problems, problem{file: file, msg: fmt.Sprintf(format, args...)})
+}
+
+// unsupportedf records a use of an unsupported feature in the file.
+func (v *validator) unsupportedf(file, format string, args ...interface{}) {
+	v.problems = append(v.problems, problem{file: file, msg: fmt.Sprintf(format, args...), unsupported: true})
+}
+
+// check checks the map. Errors that keep the map from loading stop the
+// checks, as everything after depends on it.
+func (v *validator) check() {
+	src, err := fs.ReadFile(v.fsys, v.name)
+	if err != nil {
+		v.errorf("", "%v", err)
+		return
+	}
+	v.features("", src)
+	m, err := tiled.Decode(bytes.NewReader(src))
+	if err != nil {
+		// Layers compressed with an unsupported method cannot be decoded,
+		// which features reported already.
+		if !v.compression {
+			v.errorf("", "%v", err)
+		}
+		return
+	}
+
+	// Decode does not load external tilesets, look for them first to report
+	// every missing one.
+	dir := path.Dir(v.name)
+	missing := false
+	for _, ts := range m.Tilesets {
+		if len(ts.Source) == 0 {
+			continue
+		}
+		data, err := fs.ReadFile(v.fsys, path.Join(dir, ts.Source))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			v.errorf("", "missing external tileset %q", ts.Source)
+			missing = true
+		case err != nil:
+			v.errorf("", "unreadable external tileset %q: %v", ts.Source, err)
+			missing = true
+		default:
+			v.features(ts.Source, data)
+		}
+	}
+	if missing {
+		return
+	}
+	if m, err = tiled.Open(v.fsys, v.name); err != nil {
+		v.errorf("", "%v", err)
+		return
+	}
+
+	used := v.tiles(m)
+	for _, ts := range m.Tilesets {
+		file := ts.Source
+		v.image(file, ts.Image)
+		for _, t := range ts.Tiles {
+			v.image(file, t.Image)
+		}
+
+		// Tiled draws tiles of any size, but azul3d_tmx only draws tiles as
+		// wide as their cells, which may be taller to extend upwards (e.g.
+		// the blocks of isometric maps). Image collections hold tiles of any
+		// size, which are usually placed as objects.
+		if used[ts] && ts.Image != nil && (ts.TileWidth != m.TileWidth || ts.TileHeight < m.TileHeight) {
+			v.unsupportedf(file, "tileset %q has %dx%d tiles, which do not fit the %dx%d cells of the map",
+				ts.Name, ts.TileWidth, ts.TileHeight, m.TileWidth, m.TileHeight)
+		}
+	}
+	var images func(layers []tiled.Layer)
+	images = func(layers []tiled.Layer) {
+		for _, l := range layers {
+			switch l := l.(type) {
+			case *tiled.Group:
+				images(l.Layers)
+			case *tiled.ImageLayer:
+				v.image("", l.Image)
+			}
+		}
+	}
+	images(m.Layers)
+}
+
+// tiles checks that every tile of the tile layers and tile objects of the map
+// is in a tileset, and returns the tilesets used by the tile layers.
+func (v *validator) tiles(m *tiled.Map) map[*tiled.Tileset]bool {
+	inRange := func(gid tiled.GID) (*tiled.Tileset, bool) {
+		ts, id := m.Tileset(gid)
+		if ts == nil {
+			return nil, false
+		}
+		if ts.Image == nil {
+			t, ok := ts.Tiles[id]
+			return ts, ok && t.Image != nil
+		}
+		return ts, id < ts.TileCount
+	}
+
+	used := make(map[*tiled.Tileset]bool)
+	for _, l := range m.TileLayers {
+		var (
+			bad   int
+			first image.Point
+			gid   tiled.GID
+		)
+		b := l.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				g := l.At(x, y)
+				if g.ID() == 0 {
+					continue
+				}
+				ts, ok := inRange(g)
+				if ok {
+					used[ts] = true
+					continue
+				}
+				if bad == 0 {
+					first, gid = image.Pt(x, y), g
+				}
+				bad++
+			}
+		}
+		if bad > 0 {
+			v.errorf("", "layer %q has %d tiles with out-of-range GIDs, the first GID %d at %d,%d",
+				l.Name, bad, gid.ID(), first.X, first.Y)
+		}
+	}
+	for _, g := range m.ObjectGroups {
+		for _, o := range g.Objects {
+			if o.Shape != tiled.TileShape {
+				continue
+			}
+			if _, ok := inRange(o.GID); !ok {
+				v.errorf("", "object %d of layer %q has the out-of-range GID %d", o.ID, g.Name, o.GID.ID())
+			}
+		}
+	}
+	return used
+}
+
+// image checks that the image can be decoded, and that it is the size written
+// in the file referencing it.
+func (v *validator) image(file string, img *tiled.Image) {
+	if img == nil || len(img.Source) == 0 || v.images[img.Path] {
+		return
+	}
+	v.images[img.Path] = true
+
+	f, err := v.fsys.Open(img.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		v.errorf(file, "missing image %q", img.Source)
+		return
+	}
+	if err != nil {
+		v.errorf(file, "unreadable image %q: %v", img.Source, err)
+		return
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		v.errorf(file, "unreadable image %q: %v", img.Source, err)
+		return
+	}
+	if img.Width > 0 && img.Height > 0 && (config.Width != img.Width || config.Height != img.Height) {
+		v.errorf(file, "image %q is %dx%d, but %dx%d is written", img.Source, config.Width, config.Height, img.Width, img.Height)
+	}
+}
+
+// layerElements are the elements that may appear within maps and groups.
+var layerElements = map[string]bool{
+	"properties":     true,
+	"tileset":        true,
+	"layer":          true,
+	"objectgroup":    true,
+	"imagelayer":     true,
+	"group":          true,
+	"editorsettings": true,
+}
+
+// features records the features used by the TMX or TSX file src that are not
+// supported, once for each with the number of times it is used.
+func (v *validator) features(file string, src []byte) {
+	var (
+		found   = make(map[string]int)
+		order   []string
+		parents []string
+	)
+	use := func(format string, args ...interface{}) {
+		f := fmt.Sprintf(format, args...)
+		if found[f] == 0 {
+			order = append(order, f)
+		}
+		found[f]++
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(src))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Decode reports files that cannot be read.
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := make(map[string]string, len(t.Attr))
+			for _, a := range t.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			parent := ""
+			if len(parents) > 0 {
+				parent = parents[len(parents)-1]
+			}
+			parents = append(parents, t.Name.Local)
+
+			name := t.Name.Local
+			if (parent == "map" || parent == "group") && !layerElements[name] {
+				use("<%s> elements are ignored", name)
+			}
+			switch name {
+			case "map":
+				switch o := attrs["orientation"]; o {
+				case tiled.Orthogonal, tiled.Isometric, tiled.Staggered, tiled.Hexagonal:
+				default:
+					use("the %q orientation is not supported", o)
+				}
+			case "data":
+				switch c := attrs["compression"]; c {
+				case "", "zlib", "gzip":
+				default:
+					use("%s compression of layers is not supported", c)
+					v.compression = true
+				}
+			case "imagelayer":
+				if attrs["repeatx"] == "1" || attrs["repeaty"] == "1" {
+					use("repeated image layers are drawn once")
+				}
+			case "tileset":
+				if a := attrs["objectalignment"]; len(a) > 0 && a != "unspecified" {
+					use("the object alignment of tilesets is ignored")
+				}
+				if attrs["tilerendersize"] == "grid" {
+					use("the tile render size of tilesets is ignored")
+				}
+				if attrs["fillmode"] == "preserve-aspect-fit" {
+					use("the fill mode of tilesets is ignored")
+				}
+			case "tile":
+				if parent == "tileset" && (len(attrs["x"]) > 0 || len(attrs["y"]) > 0 || len(attrs["width"]) > 0) {
+					use("sub-rectangles of tile images are ignored")
+				}
+			case "object":
+				if len(attrs["template"]) > 0 {
+					use("object templates are not loaded")
+				}
+			case "text":
+				if parent == "object" {
+					use("text objects are not drawn")
+				}
+			case "image":
+				if len(attrs["source"]) == 0 {
+					use("embedded images are not loaded")
+				}
+				if len(attrs["trans"]) > 0 {
+					use("transparent colors of images are ignored")
+				}
+			case "property":
+				if attrs["type"] == "class" {
+					use("class properties are not loaded")
+				}
+			}
+
+		case xml.EndElement:
+			parents = parents[:len(parents)-1]
+		}
+	}
+
+	for _, f := range order {
+		msg := f
+		if n := found[f]; n > 1 {
+			msg += fmt.Sprintf(" (%d times)", n)
+		}
+		v.unsupportedf(file, "%s", msg)
+	}
+}
@@ -14,12 +14,13 @@ import (
 
 	"azul3d.org/engine/gfx"
 	"azul3d.org/engine/gfx/camera"
-	"azul3d.org/engine/gfx/gfxutil"
 	"azul3d.org/engine/gfx/window"
 	"azul3d.org/engine/keyboard"
 	math "azul3d.org/engine/lmath"
 
 	"azul3d.org/examples/abs"
+	"azul3d.org/examples/reload"
+	"azul3d.org/examples/timing"
 )
 
 const (
@@ -44,8 +45,8 @@ func gfxLoop(w window.Window, d gfx.Device) {
 	// object).
 	cam.SetPos(math.Vec3{0, -2, 0})
 
-	// Read the GLSL shaders from disk.
-	shader, err := gfxutil.OpenShader(abs.Path("azul3d_triangle/triangle"))
+	// Read the GLSL shaders.
+	shader, err := abs.OpenShader("azul3d_triangle/triangle")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -105,16 +106,32 @@ func gfxLoop(w window.Window, d gfx.Device) {
 
 	triangle.Transform.SetParent(left)
 
+	// Use the example clock for movement, so runs can be replayed.
+	clk := timing.For(d)
+
+	// Reload the shaders whenever they are edited on disk.
+	watcher := reload.New(d)
+	watcher.Shader(shader, "azul3d_triangle/triangle")
+	defer watcher.Close()
+
 	// Create a channel of events.
 	events := make(chan window.Event, 256)
 
 	// Have the window notify our channel whenever events occur.
-	w.Notify(events, window.KeyboardTypedEvents|window.FramebufferResizedEvents)
+	w.Notify(events, window.KeyboardTypedEvents|window.FramebufferResizedEvents|window.CloseEvents)
 
 	for {
+		// Swap in any reloaded assets.
+		watcher.Update()
+
 		// Handle each pending event.
 		window.Poll(events, func(e window.Event) {
 			switch ev := e.(type) {
+			case window.Close:
+				// The program exits once the window closes, stop watching
+				// first.
+				watcher.Close()
+
 			case window.FramebufferResized:
 				// Update the camera's projection matrix for the new width and
 				// height.
@@ -188,7 +205,7 @@ func gfxLoop(w window.Window, d gfx.Device) {
 		}
 
 		// Apply movement relative to the frame rate.
-		v = v.MulScalar(d.Clock().Dt())
+		v = v.MulScalar(clk.Dt())
 
 		// Update the triangle's transformation matrix.
 		if kb.Down(keyboard.R) {
@@ -0,0 +1,223 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package camera2d controls the camera of a 2D view, such as that of a tiled
+// map. It pans with the keyboard, the edges of the view and dragging, gliding
+// to a stop, zooms in pixel-perfect steps between limits, and keeps the view
+// within the bounds of the world.
+//
+// It only does the math, in pixels of the world and of the view, such that it
+// works without a window: the caller feeds it input each frame and places its
+// camera at Snapped.
+package camera2d
+
+import (
+	"image"
+	"math"
+
+	"azul3d.org/engine/lmath"
+)
+
+// Controller is the state of a 2D camera and how it moves. Positions in the
+// world have Y pointing down, as on the screen.
+type Controller struct {
+	// Pos is the position in world pixels at the center of the view.
+	Pos lmath.Vec2
+
+	// Vel is the velocity the view pans at, in world pixels per second.
+	Vel lmath.Vec2
+
+	// Zoom is how many pixels of the view a world pixel spans. It is kept at
+	// the zoom levels (see Level) between MinZoom and MaxZoom.
+	Zoom, MinZoom, MaxZoom float64
+
+	// Size is the size of the view in pixels.
+	Size lmath.Vec2
+
+	// Bounds is the area in world pixels that the view is kept within, or
+	// centered on if it is smaller than the view. The view moves freely if
+	// it is empty.
+	Bounds image.Rectangle
+
+	// Speed is how fast the keyboard and the edges of the view pan, in pixels
+	// of the view per second.
+	Speed float64
+
+	// Edge is the width in pixels of the border of the view within which the
+	// cursor pans, zero disabling edge panning.
+	Edge float64
+
+	// Damping is the rate per second at which the velocity approaches that of
+	// the input: the higher it is, the quicker the view starts panning and
+	// the sooner it stops gliding once the input stops.
+	Damping float64
+}
+
+// stopSpeed is the speed, in pixels of the view per second, below which a
+// gliding view stops.
+const stopSpeed = 1
+
+// New returns a controller of a view of the given size in pixels, centered on
+// the origin of the world at a zoom of one, with default limits and speeds.
+func New(size lmath.Vec2) *Controller {
+	return &Controller{
+		Zoom:    1,
+		MinZoom: 1.0 / 4,
+		MaxZoom: 8,
+		Size:    size,
+		Speed:   800,
+		Edge:    16,
+		Damping: 10,
+	}
+}
+
+// Input is the input that pans the view during a frame.
+type Input struct {
+	// Keys is the direction that keys pan in, each component being -1, 0 or 1
+	// (e.g. Y is -1 while the up arrow key is down).
+	Keys lmath.Vec2
+
+	// Cursor is the position in pixels of the cursor in the view, from its
+	// top-left corner, valid only if HasCursor is true.
+	Cursor    lmath.Vec2
+	HasCursor bool
+}
+
+// EdgeDir returns the direction that the cursor at p, in pixels of the view,
+// pans in: each component is -1 or 1 when the cursor is within Edge pixels of
+// the left or top, or right or bottom, edge of the view and zero otherwise.
+func (c *Controller) EdgeDir(p lmath.Vec2) lmath.Vec2 {
+	if c.Edge <= 0 {
+		return lmath.Vec2{}
+	}
+	axis := func(v, size float64) float64 {
+		switch {
+		case v < c.Edge:
+			return -1
+		case v >= size-c.Edge:
+			return 1
+		}
+		return 0
+	}
+	return lmath.Vec2{X: axis(p.X, c.Size.X), Y: axis(p.Y, c.Size.Y)}
+}
+
+// Update advances the controller by dt seconds of the input, moving and then
+// clamping the view.
+func (c *Controller) Update(dt float64, in Input) {
+	dir := in.Keys
+	if in.HasCursor {
+		dir = dir.Add(c.EdgeDir(in.Cursor))
+	}
+	dir = lmath.Vec2{X: clamp(dir.X, -1, 1), Y: clamp(dir.Y, -1, 1)}
+	if l := dir.Length(); l > 1 {
+		// Pan diagonally as fast as along an axis.
+		dir = dir.MulScalar(1 / l)
+	}
+
+	// The velocity approaches that of the input exponentially, at the same
+	// rate whatever the frame rate is.
+	target := dir.MulScalar(c.Speed / c.Zoom)
+	k := math.Exp(-c.Damping * dt)
+	c.Vel = target.Add(c.Vel.Sub(target).MulScalar(k))
+	if dir == (lmath.Vec2{}) && c.Vel.Length()*c.Zoom < stopSpeed {
+		c.Vel = lmath.Vec2{}
+	}
+	c.Pos = c.Pos.Add(c.Vel.MulScalar(dt))
+	c.Clamp()
+}
+
+// Move moves the view by d, in pixels of the view, as when dragging it. It
+// stops any gliding.
+func (c *Controller) Move(d lmath.Vec2) {
+	c.Pos = c.Pos.Add(d.MulScalar(1 / c.Zoom))
+	c.Vel = lmath.Vec2{}
+	c.Clamp()
+}
+
+// Level returns the zoom level of the zoom: level n >= 0 is a zoom of n+1, at
+// which a world pixel spans n+1 pixels of the view, and level -n is a zoom of
+// 1/(n+1), at which a pixel of the view spans n+1 world pixels. Zooms between
+// levels are rounded to the nearest one.
+func Level(zoom float64) int {
+	if zoom >= 1 {
+		return int(math.Round(zoom)) - 1
+	}
+	return 1 - int(math.Round(1/zoom))
+}
+
+// LevelZoom returns the zoom at the zoom level, see Level.
+func LevelZoom(level int) float64 {
+	if level >= 0 {
+		return float64(level + 1)
+	}
+	return 1 / float64(1-level)
+}
+
+// ZoomAt zooms in (or out, if negative) by the number of zoom levels, at most
+// to MinZoom or MaxZoom, keeping the world position under p, in pixels of the
+// view, in place.
+func (c *Controller) ZoomAt(levels int, p lmath.Vec2) {
+	level := Level(c.Zoom) + levels
+	for level > Level(c.Zoom) && LevelZoom(level) > c.MaxZoom {
+		level--
+	}
+	for level < Level(c.Zoom) && LevelZoom(level) < c.MinZoom {
+		level++
+	}
+	before := c.ViewToWorld(p)
+	c.Zoom = LevelZoom(level)
+	c.Pos = c.Pos.Add(before.Sub(c.ViewToWorld(p)))
+	c.Clamp()
+}
+
+// ViewToWorld returns the position in world pixels under p, in pixels of the
+// view.
+func (c *Controller) ViewToWorld(p lmath.Vec2) lmath.Vec2 {
+	return c.Pos.Add(p.Sub(c.Size.MulScalar(0.5)).MulScalar(1 / c.Zoom))
+}
+
+// WorldToView returns the position in pixels of the view of p, in world
+// pixels.
+func (c *Controller) WorldToView(p lmath.Vec2) lmath.Vec2 {
+	return p.Sub(c.Pos).MulScalar(c.Zoom).Add(c.Size.MulScalar(0.5))
+}
+
+// Clamp moves the view within Bounds, or centers it on them along the axes it
+// is larger than them, stopping the view from gliding out of them.
+func (c *Controller) Clamp() {
+	if c.Bounds.Empty() {
+		return
+	}
+	axis := func(pos, vel *float64, min, max int, size float64) {
+		half := size / 2 / c.Zoom
+		lo, hi := float64(min)+half, float64(max)-half
+		p := (lo + hi) / 2
+		if lo <= hi {
+			p = clamp(*pos, lo, hi)
+		}
+		if p != *pos {
+			*pos, *vel = p, 0
+		}
+	}
+	axis(&c.Pos.X, &c.Vel.X, c.Bounds.Min.X, c.Bounds.Max.X, c.Size.X)
+	axis(&c.Pos.Y, &c.Vel.Y, c.Bounds.Min.Y, c.Bounds.Max.Y, c.Size.Y)
+}
+
+// Snapped returns the position near Pos to place the camera at, such that the
+// edges of world pixels line up with those of the pixels of the view and the
+// world is drawn without seams or uneven pixels.
+func (c *Controller) Snapped() lmath.Vec2 {
+	// The left and top edges of the view must be on a pixel of the view.
+	snap := func(pos, size float64) float64 {
+		edge := math.Round(pos*c.Zoom - size/2)
+		return (edge + size/2) / c.Zoom
+	}
+	return lmath.Vec2{X: snap(c.Pos.X, c.Size.X), Y: snap(c.Pos.Y, c.Size.Y)}
+}
+
+// clamp returns v limited to the range min to max.
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
@@ -0,0 +1,175 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package camera2d
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"azul3d.org/engine/lmath"
+)
+
+// near reports whether the vectors are within eps of each other.
+func near(a, b lmath.Vec2, eps float64) bool {
+	return math.Abs(a.X-b.X) <= eps && math.Abs(a.Y-b.Y) <= eps
+}
+
+func TestLevel(t *testing.T) {
+	for level := -10; level <= 10; level++ {
+		zoom := LevelZoom(level)
+		if got := Level(zoom); got != level {
+			t.Errorf("level of zoom %v is %d, want %d", zoom, got, level)
+		}
+	}
+
+	// Zooms between levels round to the nearest one.
+	for _, tst := range []struct {
+		zoom  float64
+		level int
+	}{
+		{2.4, 1},
+		{2.6, 2},
+		{0.9, 0},
+		{0.3, -2},
+		{0.26, -3},
+	} {
+		if got := Level(tst.zoom); got != tst.level {
+			t.Errorf("level of zoom %v is %d, want %d", tst.zoom, got, tst.level)
+		}
+	}
+}
+
+func TestZoomAt(t *testing.T) {
+	c := New(lmath.Vec2{X: 640, Y: 480})
+	c.Pos = lmath.Vec2{X: 100, Y: -40}
+
+	// The world position under the cursor stays under it.
+	cursor := lmath.Vec2{X: 500, Y: 120}
+	for _, levels := range []int{1, 2, -1, -4, 3} {
+		before := c.ViewToWorld(cursor)
+		c.ZoomAt(levels, cursor)
+		if after := c.ViewToWorld(cursor); !near(after, before, 1e-9) {
+			t.Errorf("zooming by %d to %v moved %v under the cursor to %v", levels, c.Zoom, before, after)
+		}
+		if v := c.WorldToView(before); !near(v, cursor, 1e-9) {
+			t.Errorf("zooming by %d to %v moved %v to %v in the view, want %v", levels, c.Zoom, before, v, cursor)
+		}
+	}
+
+	// The zoom stops at the limits, on a level within them.
+	c.ZoomAt(100, cursor)
+	if c.Zoom != c.MaxZoom {
+		t.Errorf("zoomed in to %v, want MaxZoom %v", c.Zoom, c.MaxZoom)
+	}
+	c.ZoomAt(-100, cursor)
+	if c.Zoom != c.MinZoom {
+		t.Errorf("zoomed out to %v, want MinZoom %v", c.Zoom, c.MinZoom)
+	}
+	c.MinZoom, c.MaxZoom = 0.3, 5.5
+	c.ZoomAt(100, cursor)
+	if c.Zoom != 5 {
+		t.Errorf("zoomed in to %v, want 5 below MaxZoom %v", c.Zoom, c.MaxZoom)
+	}
+	c.ZoomAt(-100, cursor)
+	if c.Zoom != 1.0/3 {
+		t.Errorf("zoomed out to %v, want 1/3 above MinZoom %v", c.Zoom, c.MinZoom)
+	}
+}
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		zoom      float64
+		bounds    image.Rectangle
+		pos, want lmath.Vec2
+	}{
+		// Moving freely without bounds.
+		{1, image.Rectangle{}, lmath.Vec2{X: -500, Y: 900}, lmath.Vec2{X: -500, Y: 900}},
+		// Within the bounds already.
+		{1, image.Rect(0, 0, 1000, 500), lmath.Vec2{X: 300, Y: 200}, lmath.Vec2{X: 300, Y: 200}},
+		// Moved back within the bounds, by half the view.
+		{1, image.Rect(0, 0, 1000, 500), lmath.Vec2{X: -50, Y: 30}, lmath.Vec2{X: 100, Y: 50}},
+		{1, image.Rect(0, 0, 1000, 500), lmath.Vec2{X: 2000, Y: 480}, lmath.Vec2{X: 900, Y: 450}},
+		// Half the view spans fewer world pixels when zoomed in.
+		{2, image.Rect(0, 0, 1000, 500), lmath.Vec2{X: -50, Y: 30}, lmath.Vec2{X: 50, Y: 30}},
+		// Centered along the axes the bounds are smaller than the view.
+		{1, image.Rect(0, 0, 100, 500), lmath.Vec2{X: 300, Y: 10}, lmath.Vec2{X: 50, Y: 50}},
+		{0.25, image.Rect(-200, 100, 600, 300), lmath.Vec2{X: 0, Y: 0}, lmath.Vec2{X: 200, Y: 200}},
+	}
+	for _, tst := range tests {
+		c := New(lmath.Vec2{X: 200, Y: 100})
+		c.Zoom = tst.zoom
+		c.Bounds = tst.bounds
+		c.Pos = tst.pos
+		c.Vel = lmath.Vec2{X: 10, Y: 10}
+		c.Clamp()
+		if c.Pos != tst.want {
+			t.Errorf("zoom %v, bounds %v: %v clamped to %v, want %v", tst.zoom, tst.bounds, tst.pos, c.Pos, tst.want)
+		}
+
+		// The view stops gliding along the axes it was moved.
+		if (c.Vel.X == 0) != (c.Pos.X != tst.pos.X) || (c.Vel.Y == 0) != (c.Pos.Y != tst.pos.Y) {
+			t.Errorf("zoom %v, bounds %v: velocity %v after clamping %v to %v", tst.zoom, tst.bounds, c.Vel, tst.pos, c.Pos)
+		}
+	}
+}
+
+// TestUpdateDamping checks that the view pans the same whatever the frame
+// rate is, as the velocity approaches that of the input.
+func TestUpdateDamping(t *testing.T) {
+	const seconds = 1
+	for _, fps := range []int{15, 60, 240} {
+		c := New(lmath.Vec2{X: 640, Y: 480})
+		c.Damping = 2
+		dt := 1 / float64(fps)
+		in := Input{Keys: lmath.Vec2{X: 1}}
+		for i := 0; i < seconds*fps; i++ {
+			c.Update(dt, in)
+		}
+
+		// The velocity is exact, and the position within a frame of panning
+		// at full speed of the integral of it.
+		k := math.Exp(-c.Damping * seconds)
+		vel := c.Speed * (1 - k)
+		pos := c.Speed * (seconds - (1-k)/c.Damping)
+		if math.Abs(c.Vel.X-vel) > 1e-6 || c.Vel.Y != 0 {
+			t.Errorf("%d fps: velocity %v after %vs, want %v", fps, c.Vel, seconds, vel)
+		}
+		if math.Abs(c.Pos.X-pos) > c.Speed*dt || c.Pos.Y != 0 {
+			t.Errorf("%d fps: position %v after %vs, want %v", fps, c.Pos, seconds, pos)
+		}
+
+		// Once the keys are released, the view glides to a stop.
+		for i := 0; i < 10*fps; i++ {
+			c.Update(dt, Input{})
+		}
+		if c.Vel != (lmath.Vec2{}) {
+			t.Errorf("%d fps: still gliding at %v", fps, c.Vel)
+		}
+	}
+}
+
+func TestSnapped(t *testing.T) {
+	for _, size := range []lmath.Vec2{{X: 640, Y: 480}, {X: 641, Y: 479}} {
+		for _, level := range []int{-3, -1, 0, 1, 2, 7} {
+			for _, pos := range []lmath.Vec2{{X: 0, Y: 0}, {X: 10.3, Y: -7.77}, {X: -1234.56, Y: 98765.4321}} {
+				c := New(size)
+				c.Zoom = LevelZoom(level)
+				c.Pos = pos
+				s := c.Snapped()
+
+				// The top-left corner of the view is on a pixel of the view,
+				// and the camera within half of one of Pos.
+				edge := lmath.Vec2{X: s.X*c.Zoom - size.X/2, Y: s.Y*c.Zoom - size.Y/2}
+				if math.Abs(edge.X-math.Round(edge.X)) > 1e-6 || math.Abs(edge.Y-math.Round(edge.Y)) > 1e-6 {
+					t.Errorf("size %v, zoom %v: %v snapped to %v, with the view edge at %v", size, c.Zoom, pos, s, edge)
+				}
+				if !near(s, pos, 0.5/c.Zoom+1e-9) {
+					t.Errorf("size %v, zoom %v: %v snapped to %v, further than half a pixel of the view", size, c.Zoom, pos, s)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+#!/bin/sh
+# Renders every example offscreen and checks it against its golden image, or
+# with "update" as the first argument writes new golden images.
+#
+#   ./golden.sh [check|update]
+set -e
+cd "$(dirname "$0")"
+
+packages="./azul3d_clearing ./azul3d_debug_camera ./azul3d_events ./azul3d_mandel
+	./azul3d_multiwindow ./azul3d_rtt ./azul3d_stencil ./azul3d_texcoords ./azul3d_tmx
+	./azul3d_triangle"
+case "${1:-check}" in
+check)
+	go test -run TestGolden $packages
+	;;
+update)
+	go test -run TestGolden $packages -args -update
+	;;
+*)
+	echo "usage: $0 [check|update]" >&2
+	exit 2
+	;;
+esac
@@ -0,0 +1,256 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package golden checks the frames rendered by the examples against golden
+// images, to catch rendering regressions without a GPU.
+//
+// Each example has a TestGolden test calling Run, which runs the example's
+// gfxLoop on a headless device (see the headless package) with a fixed clock
+// and random seed (see the timing package) for a set number of frames. The
+// last frame is compared against the golden image at testdata/golden.png in
+// the example's directory, or written there when the tests are run with the
+// -update flag:
+//
+//	go test ./azul3d_triangle -run TestGolden -args -update
+//
+// Examples without a golden image yet skip the test until one is written.
+// The golden.sh script in the examples directory does this for every example.
+package golden
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // Add JPEG decoder for Example.Images.
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/window"
+
+	"azul3d.org/examples/abs"
+	"azul3d.org/examples/headless"
+	"azul3d.org/examples/timing"
+)
+
+// update makes Run write the golden images instead of checking against them.
+var update = flag.Bool("update", false, "write the golden images instead of checking against them")
+
+// Example describes an example to be checked.
+type Example struct {
+	// GfxLoop is the example's graphics loop.
+	GfxLoop func(w window.Window, d gfx.Device)
+
+	// Props are the window properties, window.DefaultProps if nil.
+	Props *window.Props
+
+	// Frames is the number of frames to render, DefaultFrames if zero.
+	Frames int
+
+	// Shaders are the fragment shaders that stand in for the example's GLSL
+	// shaders on the headless device, see headless.Device.Shaders.
+	Shaders map[string]headless.FragmentShader
+
+	// Images are the names of the image assets (see abs.FS) the example
+	// loads. The test is skipped if any of them cannot be decoded, as is the
+	// case for the Git LFS pointers checked out when LFS is not installed.
+	Images []string
+}
+
+var (
+	// DefaultFrames is the number of frames rendered when Example.Frames is
+	// zero.
+	DefaultFrames = 60
+
+	// FrameDelta is the fixed time between rendered frames.
+	FrameDelta = time.Second / 60
+
+	// Seed is the seed of the random numbers the example uses.
+	Seed int64 = 1
+
+	// Threshold is the perceptual color difference, from zero to one, above
+	// which two pixels are considered different.
+	Threshold = 0.1
+
+	// MaxDiff is the fraction of pixels that may differ before an image no
+	// longer matches its golden image.
+	MaxDiff = 0.001
+)
+
+// The golden image, and the image highlighting the pixels differing from it,
+// relative to the directory of the example's package.
+const (
+	goldenPath = "testdata/golden.png"
+	diffPath   = "testdata/golden_diff.png"
+)
+
+// Run runs the example on a headless device and checks its last frame against
+// the golden image, or writes the golden image with the -update flag. If the
+// frame differs, an image highlighting the differing pixels is written to
+// testdata/golden_diff.png and the test fails. The test is skipped if there is
+// no golden image to check against.
+func Run(t testing.TB, e Example) {
+	t.Helper()
+	if !*update {
+		if _, err := os.Stat(goldenPath); errors.Is(err, fs.ErrNotExist) {
+			t.Skipf("no golden image, run the test with -update to write %s", goldenPath)
+		}
+	}
+	for _, name := range e.Images {
+		if err := decodable(name); err != nil {
+			t.Skipf("%s cannot be decoded (is Git LFS installed?): %v", name, err)
+		}
+	}
+
+	frame := Render(e)
+	if frame == nil {
+		t.Fatal("rendered no frames")
+	}
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writePNG(goldenPath, frame); err != nil {
+			t.Fatal(err)
+		}
+		t.Log("wrote", goldenPath)
+		return
+	}
+
+	want, err := readPNG(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, diff := Compare(want, frame, Threshold)
+	if diff == nil {
+		t.Fatalf("frame is %v, golden image is %v", frame.Bounds().Size(), want.Bounds().Size())
+	}
+	total := frame.Bounds().Dx() * frame.Bounds().Dy()
+	if float64(n) > MaxDiff*float64(total) {
+		if err := writePNG(diffPath, diff); err != nil {
+			t.Fatal(err)
+		}
+		t.Fatalf("%d of %d pixels differ from %s, see %s", n, total, goldenPath, diffPath)
+	}
+	os.Remove(diffPath)
+}
+
+// decodable returns an error if the named image asset cannot be decoded.
+func decodable(name string) error {
+	f, err := abs.FS().Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _, err = image.DecodeConfig(f)
+	return err
+}
+
+// Render runs the example on a headless device with a fixed clock and random
+// seed (see the timing package) and returns the last frame it rendered.
+func Render(e Example) *image.RGBA {
+	frames := e.Frames
+	if frames == 0 {
+		frames = DefaultFrames
+	}
+	timing.Fix(FrameDelta)
+	timing.Seed(Seed)
+
+	w := headless.NewWindow(e.Props)
+	props := w.Props()
+	width, height := props.Size()
+	d := headless.New(image.Rect(0, 0, width, height), props.Precision())
+	for name, shader := range e.Shaders {
+		d.Shaders[name] = shader
+	}
+	return headless.RunDevice(e.GfxLoop, w, d, frames)
+}
+
+// Compare compares two images perceptually, using the difference in YIQ color
+// space. It returns the number of pixels whose difference exceeds the
+// threshold (from zero to one) and an image highlighting them in red over a
+// faded copy of want. If the images are not the same size, it returns a nil
+// image.
+func Compare(want, got image.Image, threshold float64) (n int, diff *image.RGBA) {
+	wb, gb := want.Bounds(), got.Bounds()
+	if wb.Size() != gb.Size() {
+		return 0, nil
+	}
+
+	// 35215 is the largest possible difference between two colors.
+	maxDelta := 35215 * threshold * threshold
+	diff = image.NewRGBA(image.Rect(0, 0, wb.Dx(), wb.Dy()))
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			a := want.At(wb.Min.X+x, wb.Min.Y+y)
+			b := got.At(gb.Min.X+x, gb.Min.Y+y)
+			if colorDelta(a, b) > maxDelta {
+				n++
+				diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+				continue
+			}
+			l := uint8(255 - (255-luma(a))/4)
+			diff.SetRGBA(x, y, color.RGBA{l, l, l, 255})
+		}
+	}
+	return n, diff
+}
+
+// blendWhite returns the 8-bit components of c blended over white.
+func blendWhite(c color.Color) (r, g, b float64) {
+	cr, cg, cb, ca := c.RGBA()
+	white := float64(0xFFFF - ca)
+	return (float64(cr) + white) / 257, (float64(cg) + white) / 257, (float64(cb) + white) / 257
+}
+
+// luma returns the 8-bit luma of c blended over white.
+func luma(c color.Color) float64 {
+	r, g, b := blendWhite(c)
+	return 0.29889531*r + 0.58662247*g + 0.11448223*b
+}
+
+// colorDelta returns the squared YIQ distance between two colors, weighted
+// for perception.
+func colorDelta(a, b color.Color) float64 {
+	r1, g1, b1 := blendWhite(a)
+	r2, g2, b2 := blendWhite(b)
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	y := 0.29889531*dr + 0.58662247*dg + 0.11448223*db
+	i := 0.59597799*dr - 0.27417610*dg - 0.32180189*db
+	q := 0.21147017*dr - 0.52261711*dg + 0.31114694*db
+	return 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+}
+
+// readPNG reads a PNG image file.
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return img, nil
+}
+
+// writePNG writes an image to a PNG file.
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,157 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package headless
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"azul3d.org/engine/gfx"
+)
+
+// canvas is a software framebuffer implementing the gfx.Canvas interface. Both
+// the device and render to texture canvases are one.
+type canvas struct {
+	d      *Device
+	bounds image.Rectangle
+	prec   gfx.Precision
+	msaa   bool
+
+	// The back buffer, drawn to directly.
+	color   []gfx.Color
+	depth   []float32
+	stencil []uint8
+
+	// The front buffer, the last rendered frame.
+	frontLock sync.Mutex
+	front     *image.RGBA
+
+	// onRender is called at the end of each Render call with the new front
+	// buffer.
+	onRender func(frame *image.RGBA)
+}
+
+// newCanvas returns a new canvas of the given size.
+func newCanvas(d *Device, bounds image.Rectangle, prec gfx.Precision) *canvas {
+	n := bounds.Dx() * bounds.Dy()
+	return &canvas{
+		d:       d,
+		bounds:  bounds,
+		prec:    prec,
+		color:   make([]gfx.Color, n),
+		depth:   make([]float32, n),
+		stencil: make([]uint8, n),
+		front:   image.NewRGBA(bounds),
+	}
+}
+
+// index returns the buffer index of the pixel at x, y.
+func (c *canvas) index(x, y int) int {
+	return (y-c.bounds.Min.Y)*c.bounds.Dx() + (x - c.bounds.Min.X)
+}
+
+// SetMSAA implements the gfx.Canvas interface. Multisampling is not
+// implemented, so it only changes what MSAA returns.
+func (c *canvas) SetMSAA(enabled bool) {
+	c.msaa = enabled
+}
+
+// MSAA implements the gfx.Canvas interface.
+func (c *canvas) MSAA() bool {
+	return c.msaa
+}
+
+// Precision implements the gfx.Canvas interface.
+func (c *canvas) Precision() gfx.Precision {
+	return c.prec
+}
+
+// Bounds implements the gfx.Canvas interface.
+func (c *canvas) Bounds() image.Rectangle {
+	return c.bounds
+}
+
+// Clear implements the gfx.Canvas interface.
+func (c *canvas) Clear(r image.Rectangle, bg gfx.Color) {
+	r = r.Intersect(c.bounds)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c.color[c.index(x, y)] = bg
+		}
+	}
+}
+
+// ClearDepth implements the gfx.Canvas interface.
+func (c *canvas) ClearDepth(r image.Rectangle, depth float64) {
+	r = r.Intersect(c.bounds)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c.depth[c.index(x, y)] = float32(depth)
+		}
+	}
+}
+
+// ClearStencil implements the gfx.Canvas interface.
+func (c *canvas) ClearStencil(r image.Rectangle, stencil int) {
+	r = r.Intersect(c.bounds)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c.stencil[c.index(x, y)] = uint8(stencil)
+		}
+	}
+}
+
+// QueryWait implements the gfx.Canvas interface. Occlusion queries are not
+// supported, so it does nothing.
+func (c *canvas) QueryWait() {}
+
+// Render implements the gfx.Canvas interface. Drawing happens immediately, so
+// Render only copies the back buffer to the front one.
+func (c *canvas) Render() {
+	frame := image.NewRGBA(c.bounds)
+	for y := c.bounds.Min.Y; y < c.bounds.Max.Y; y++ {
+		for x := c.bounds.Min.X; x < c.bounds.Max.X; x++ {
+			frame.SetRGBA(x, y, toRGBA(c.color[c.index(x, y)]))
+		}
+	}
+
+	c.frontLock.Lock()
+	c.front = frame
+	c.frontLock.Unlock()
+
+	if c.onRender != nil {
+		c.onRender(frame)
+	}
+}
+
+// Download implements the gfx.Downloadable interface. It downloads the given
+// rectangle of the last rendered frame.
+func (c *canvas) Download(r image.Rectangle, complete chan image.Image) {
+	c.frontLock.Lock()
+	front := c.front
+	c.frontLock.Unlock()
+
+	r = r.Intersect(front.Bounds())
+	img := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(img, img.Bounds(), front, r.Min, draw.Src)
+	complete <- img
+}
+
+// toRGBA converts a gfx.Color, which is not premultiplied, to a color.RGBA.
+func toRGBA(c gfx.Color) color.RGBA {
+	clamp := func(v float32) float32 {
+		return float32(math.Max(0, math.Min(1, float64(v))))
+	}
+	a := clamp(c.A)
+	return color.RGBA{
+		R: uint8(clamp(c.R)*a*255 + 0.5),
+		G: uint8(clamp(c.G)*a*255 + 0.5),
+		B: uint8(clamp(c.B)*a*255 + 0.5),
+		A: uint8(a*255 + 0.5),
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package headless implements a software rasterized gfx.Device.
+//
+// The device needs no GPU or window system, so the gfxLoop function of an
+// example can be driven offscreen (see Run) and its frames downloaded as
+// images. It is slow and only implements the subset of the gfx package the
+// examples use: clearing, drawing triangles, lines and points with depth,
+// stencil, face culling, alpha and color write state, textures, render to
+// texture and downloading. GLSL shaders cannot be executed; instead each
+// fragment is colored by a FragmentShader (see Device.Shaders).
+package headless
+
+import (
+	"image"
+	"sync"
+
+	"azul3d.org/engine/clock"
+	"azul3d.org/engine/gfx"
+)
+
+// DefaultPrecision is the precision of a device created with a zero
+// gfx.Precision.
+var DefaultPrecision = gfx.Precision{
+	RedBits: 8, GreenBits: 8, BlueBits: 8, AlphaBits: 8,
+	DepthBits:   24,
+	StencilBits: 8,
+}
+
+// Device is a software rasterized gfx.Device. It is created with New.
+type Device struct {
+	*canvas
+
+	// Shaders maps the name of a gfx.Shader to the FragmentShader used in its
+	// place. Shaders without an entry use DefaultShader.
+	Shaders map[string]FragmentShader
+
+	// PointSize is the size in pixels of rendered points.
+	PointSize int
+
+	clock *clock.Clock
+
+	// OnRender, if non-nil, is called at the end of each Render call with the
+	// frame that was rendered.
+	OnRender func(frame *image.RGBA)
+
+	texLock  sync.Mutex
+	textures map[*gfx.Texture]*texture
+}
+
+// New returns a new device with a framebuffer of the given size and precision.
+// A zero precision is replaced by DefaultPrecision.
+func New(bounds image.Rectangle, prec gfx.Precision) *Device {
+	if prec == (gfx.Precision{}) {
+		prec = DefaultPrecision
+	}
+	d := &Device{
+		Shaders:   make(map[string]FragmentShader),
+		PointSize: 1,
+		clock:     clock.New(),
+		textures:  make(map[*gfx.Texture]*texture),
+	}
+	d.canvas = newCanvas(d, bounds, prec)
+	d.canvas.onRender = func(frame *image.RGBA) {
+		d.clock.Tick()
+		if d.OnRender != nil {
+			d.OnRender(frame)
+		}
+	}
+	return d
+}
+
+// Clock implements the gfx.Device interface.
+func (d *Device) Clock() *clock.Clock {
+	return d.clock
+}
+
+// Info implements the gfx.Device interface.
+func (d *Device) Info() gfx.DeviceInfo {
+	return gfx.DeviceInfo{
+		Name:            "Software Rasterizer",
+		Vendor:          "azul3d.org/examples/headless",
+		MaxTextureSize:  8192,
+		NPOT:            true,
+		AlphaToCoverage: true,
+		RTTFormats: gfx.RTTFormats{
+			ColorFormats:   []gfx.TexFormat{gfx.RGBA, gfx.RGB},
+			DepthFormats:   []gfx.DSFormat{gfx.Depth24},
+			StencilFormats: []gfx.DSFormat{gfx.Depth24AndStencil8},
+		},
+	}
+}
+
+// LoadMesh implements the gfx.Device interface.
+func (d *Device) LoadMesh(m *gfx.Mesh, done chan *gfx.Mesh) {
+	m.Lock()
+	m.Loaded = true
+	m.Unlock()
+	select {
+	case done <- m:
+	default:
+	}
+}
+
+// LoadTexture implements the gfx.Device interface.
+func (d *Device) LoadTexture(t *gfx.Texture, done chan *gfx.Texture) {
+	d.texture(t)
+	select {
+	case done <- t:
+	default:
+	}
+}
+
+// LoadShader implements the gfx.Device interface. Shaders are never compiled,
+// so they always load without error.
+func (d *Device) LoadShader(s *gfx.Shader, done chan *gfx.Shader) {
+	s.Lock()
+	s.Loaded = true
+	s.Error = nil
+	s.Unlock()
+	select {
+	case done <- s:
+	default:
+	}
+}
+
+// RenderToTexture implements the gfx.Device interface. Only the color buffer
+// is ever written to a texture, cfg.Depth and cfg.Stencil are ignored.
+func (d *Device) RenderToTexture(cfg gfx.RTTConfig) gfx.Canvas {
+	if cfg.Bounds.Empty() {
+		return nil
+	}
+	c := newCanvas(d, cfg.Bounds, d.prec)
+	c.onRender = func(frame *image.RGBA) {
+		if cfg.Color == nil {
+			return
+		}
+		d.texLock.Lock()
+		d.textures[cfg.Color] = newTexture(frame)
+		d.texLock.Unlock()
+
+		cfg.Color.Lock()
+		cfg.Color.Bounds = frame.Bounds()
+		cfg.Color.Loaded = true
+		cfg.Color.Unlock()
+	}
+	return c
+}
+
+// texture returns the sampler for t, loading t first if needed.
+func (d *Device) texture(t *gfx.Texture) *texture {
+	d.texLock.Lock()
+	defer d.texLock.Unlock()
+
+	t.Lock()
+	defer t.Unlock()
+	tex, ok := d.textures[t]
+	if ok && t.Loaded {
+		return tex
+	}
+	if t.Source == nil {
+		// Not yet rendered to (see RenderToTexture), or no image at all.
+		return tex
+	}
+	tex = newTexture(t.Source)
+	d.textures[t] = tex
+	t.Loaded = true
+	if !t.KeepDataOnLoad {
+		t.Source = nil
+	}
+	return tex
+}
+
+// shader returns the FragmentShader to use in place of s, and the inputs of
+// s.
+func (d *Device) shader(s *gfx.Shader) (FragmentShader, map[string]interface{}) {
+	if s == nil {
+		return DefaultShader, nil
+	}
+	s.Lock()
+	s.Loaded = true
+	name, inputs := s.Name, s.Inputs
+	s.Unlock()
+	if f, ok := d.Shaders[name]; ok {
+		return f, inputs
+	}
+	return DefaultShader, inputs
+}
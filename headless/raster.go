@@ -0,0 +1,501 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package headless
+
+import (
+	"image"
+	"math"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+)
+
+// Indices into vertex.varying.
+const (
+	vColor    = 0 // R, G, B, A
+	vTexCoord = 4 // U, V
+	vVertex   = 6 // X, Y, Z
+	nVarying  = 9
+)
+
+// vertex is a vertex after transformation into clip space.
+type vertex struct {
+	clip    [4]float64
+	varying [nVarying]float64
+}
+
+// lerp linearly interpolates between vertices a and b.
+func (a vertex) lerp(b vertex, t float64) vertex {
+	var v vertex
+	for i := range v.clip {
+		v.clip[i] = a.clip[i] + (b.clip[i]-a.clip[i])*t
+	}
+	for i := range v.varying {
+		v.varying[i] = a.varying[i] + (b.varying[i]-a.varying[i])*t
+	}
+	return v
+}
+
+// positioner is implemented by gfx.Transform, and so by objects and cameras.
+type positioner interface {
+	ConvertPos(p lmath.Vec3, c gfx.CoordConv) lmath.Vec3
+}
+
+// affine is an affine transformation: p' = origin + p.X*x + p.Y*y + p.Z*z.
+type affine struct {
+	origin, x, y, z lmath.Vec3
+}
+
+// transform transforms p.
+func (a affine) transform(p lmath.Vec3) lmath.Vec3 {
+	return a.origin.Add(a.x.MulScalar(p.X)).Add(a.y.MulScalar(p.Y)).Add(a.z.MulScalar(p.Z))
+}
+
+// modelView returns the transformation from the object's local space into the
+// camera's local space (or world space, if the camera is nil). Transforms are
+// affine, so the conversion of the origin and basis vectors fully describes it.
+func modelView(o *gfx.Object, cam gfx.Camera) affine {
+	convert := func(p lmath.Vec3) lmath.Vec3 {
+		if o.Transform != nil {
+			p = o.ConvertPos(p, gfx.LocalToWorld)
+		}
+		if c, ok := cam.(positioner); ok {
+			p = c.ConvertPos(p, gfx.WorldToLocal)
+		}
+		return p
+	}
+	origin := convert(lmath.Vec3{})
+	return affine{
+		origin: origin,
+		x:      convert(lmath.Vec3{X: 1}).Sub(origin),
+		y:      convert(lmath.Vec3{Y: 1}).Sub(origin),
+		z:      convert(lmath.Vec3{Z: 1}).Sub(origin),
+	}
+}
+
+// project transforms a point in the camera's local space into clip space. The
+// Z-up right-handed coordinate system the engine uses is converted into the
+// Y-up one the projection matrix expects, then the projection is applied.
+func project(p lmath.Vec3, proj *gfx.Mat4) [4]float64 {
+	if proj == nil {
+		return [4]float64{p.X, p.Y, p.Z, 1}
+	}
+	in := [4]float64{p.X, p.Z, -p.Y, 1}
+	var out [4]float64
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 4; i++ {
+			out[j] += in[i] * float64(proj[i][j])
+		}
+	}
+	return out
+}
+
+// Draw implements the gfx.Canvas interface. As with the OpenGL device, the
+// object is drawn to the viewport of the whole canvas, and r is a scissor
+// rectangle outside of which nothing is drawn.
+func (c *canvas) Draw(r image.Rectangle, o *gfx.Object, cam gfx.Camera) {
+	r = r.Intersect(c.bounds)
+	if r.Empty() {
+		return
+	}
+
+	o.RLock()
+	defer o.RUnlock()
+
+	mv := modelView(o, cam)
+	var proj *gfx.Mat4
+	if cam != nil {
+		p := cam.Projection()
+		proj = &p
+	}
+
+	// Load the textures and pick the shader.
+	frag := &Fragment{textures: o.Textures}
+	for _, t := range o.Textures {
+		frag.samplers = append(frag.samplers, c.d.texture(t))
+	}
+	shader, inputs := c.d.shader(o.Shader)
+	frag.Inputs = inputs
+
+	state := o.State
+	if state == nil {
+		state = gfx.NewState()
+	}
+	rs := &rasterState{
+		c:      c,
+		r:      r,
+		view:   c.bounds,
+		state:  state,
+		shader: shader,
+		frag:   frag,
+	}
+
+	for _, m := range o.Meshes {
+		m.Lock()
+		m.Loaded = true
+		verts := make([]vertex, len(m.Vertices))
+		for i, v := range m.Vertices {
+			local := lmath.Vec3{X: float64(v.X), Y: float64(v.Y), Z: float64(v.Z)}
+			verts[i].clip = project(mv.transform(local), proj)
+			vr := &verts[i].varying
+			vr[vColor], vr[vColor+1], vr[vColor+2], vr[vColor+3] = 1, 1, 1, 1
+			if i < len(m.Colors) {
+				col := m.Colors[i]
+				vr[vColor] = float64(col.R)
+				vr[vColor+1] = float64(col.G)
+				vr[vColor+2] = float64(col.B)
+				vr[vColor+3] = float64(col.A)
+			}
+			if len(m.TexCoords) > 0 && i < len(m.TexCoords[0].Slice) {
+				tc := m.TexCoords[0].Slice[i]
+				vr[vTexCoord] = float64(tc.U)
+				vr[vTexCoord+1] = float64(tc.V)
+			}
+			vr[vVertex] = local.X
+			vr[vVertex+1] = local.Y
+			vr[vVertex+2] = local.Z
+		}
+		indices := m.Indices
+		primitive := m.Primitive
+		m.Unlock()
+
+		at := func(i int) vertex {
+			if indices != nil {
+				return verts[indices[i]]
+			}
+			return verts[i]
+		}
+		n := len(verts)
+		if indices != nil {
+			n = len(indices)
+		}
+		switch primitive {
+		case gfx.Points:
+			for i := 0; i < n; i++ {
+				rs.point(at(i))
+			}
+		case gfx.Lines:
+			for i := 0; i+1 < n; i += 2 {
+				rs.line(at(i), at(i+1))
+			}
+		default:
+			for i := 0; i+2 < n; i += 3 {
+				rs.triangle(at(i), at(i+1), at(i+2))
+			}
+		}
+	}
+}
+
+// rasterState is the state for rasterizing the primitives of one object.
+type rasterState struct {
+	c      *canvas
+	r      image.Rectangle // The scissor rectangle.
+	view   image.Rectangle // The viewport.
+	state  *gfx.State
+	shader FragmentShader
+	frag   *Fragment
+}
+
+// clipPlanes are the distances of a clip space point to each side of the view
+// volume, positive values being inside.
+var clipPlanes = [6]func(p [4]float64) float64{
+	func(p [4]float64) float64 { return p[3] + p[0] },
+	func(p [4]float64) float64 { return p[3] - p[0] },
+	func(p [4]float64) float64 { return p[3] + p[1] },
+	func(p [4]float64) float64 { return p[3] - p[1] },
+	func(p [4]float64) float64 { return p[3] + p[2] },
+	func(p [4]float64) float64 { return p[3] - p[2] },
+}
+
+// clipPolygon clips a convex polygon against the view volume.
+func clipPolygon(poly []vertex) []vertex {
+	for _, plane := range clipPlanes {
+		if len(poly) == 0 {
+			return nil
+		}
+		var out []vertex
+		prev := poly[len(poly)-1]
+		prevDist := plane(prev.clip)
+		for _, v := range poly {
+			dist := plane(v.clip)
+			if dist >= 0 {
+				if prevDist < 0 {
+					out = append(out, prev.lerp(v, prevDist/(prevDist-dist)))
+				}
+				out = append(out, v)
+			} else if prevDist >= 0 {
+				out = append(out, prev.lerp(v, prevDist/(prevDist-dist)))
+			}
+			prev, prevDist = v, dist
+		}
+		poly = out
+	}
+	return poly
+}
+
+// screen is a vertex after the perspective divide and viewport transform.
+type screen struct {
+	x, y, z float64 // Window coordinates, z in [0, 1].
+	invW    float64
+	varying [nVarying]float64 // Divided by w.
+}
+
+// toScreen performs the perspective divide and viewport transform.
+func (rs *rasterState) toScreen(v vertex) screen {
+	invW := 1 / v.clip[3]
+	s := screen{
+		x:    float64(rs.view.Min.X) + (v.clip[0]*invW+1)/2*float64(rs.view.Dx()),
+		y:    float64(rs.view.Min.Y) + (1-v.clip[1]*invW)/2*float64(rs.view.Dy()),
+		z:    (v.clip[2]*invW + 1) / 2,
+		invW: invW,
+	}
+	for i, a := range v.varying {
+		s.varying[i] = a * invW
+	}
+	return s
+}
+
+// triangle clips and rasterizes a triangle.
+func (rs *rasterState) triangle(a, b, c vertex) {
+	poly := clipPolygon([]vertex{a, b, c})
+	if len(poly) < 3 {
+		return
+	}
+	s := make([]screen, len(poly))
+	for i, v := range poly {
+		s[i] = rs.toScreen(v)
+	}
+	for i := 1; i+1 < len(s); i++ {
+		rs.fill(s[0], s[i], s[i+1])
+	}
+}
+
+// fill rasterizes a triangle in window coordinates.
+func (rs *rasterState) fill(a, b, c screen) {
+	edge := func(a, b screen, x, y float64) float64 {
+		return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+	}
+	area := edge(a, b, c.x, c.y)
+	if area == 0 {
+		return
+	}
+
+	// Window Y points down, so counter-clockwise (front facing) triangles
+	// have a negative area here.
+	front := area < 0
+	switch rs.state.FaceCulling {
+	case gfx.BackFaceCulling:
+		if !front {
+			return
+		}
+	case gfx.FrontFaceCulling:
+		if front {
+			return
+		}
+	}
+
+	minX := int(math.Floor(math.Min(a.x, math.Min(b.x, c.x))))
+	maxX := int(math.Ceil(math.Max(a.x, math.Max(b.x, c.x))))
+	minY := int(math.Floor(math.Min(a.y, math.Min(b.y, c.y))))
+	maxY := int(math.Ceil(math.Max(a.y, math.Max(b.y, c.y))))
+	box := image.Rect(minX, minY, maxX, maxY).Intersect(rs.r)
+
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		py := float64(y) + 0.5
+		for x := box.Min.X; x < box.Max.X; x++ {
+			px := float64(x) + 0.5
+			w0 := edge(b, c, px, py) / area
+			w1 := edge(c, a, px, py) / area
+			w2 := edge(a, b, px, py) / area
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			z := w0*a.z + w1*b.z + w2*c.z
+			invW := w0*a.invW + w1*b.invW + w2*c.invW
+			var varying [nVarying]float64
+			for i := range varying {
+				varying[i] = (w0*a.varying[i] + w1*b.varying[i] + w2*c.varying[i]) / invW
+			}
+			rs.fragment(x, y, z, front, &varying)
+		}
+	}
+}
+
+// line rasterizes a line, which is only drawn if both ends are inside the
+// view volume.
+func (rs *rasterState) line(a, b vertex) {
+	if len(clipPolygon([]vertex{a})) == 0 || len(clipPolygon([]vertex{b})) == 0 {
+		return
+	}
+	sa, sb := rs.toScreen(a), rs.toScreen(b)
+	steps := int(math.Ceil(math.Max(math.Abs(sb.x-sa.x), math.Abs(sb.y-sa.y))))
+	if steps == 0 {
+		steps = 1
+	}
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x := int(math.Floor(sa.x + (sb.x-sa.x)*t))
+		y := int(math.Floor(sa.y + (sb.y-sa.y)*t))
+		if !image.Pt(x, y).In(rs.r) {
+			continue
+		}
+		v := a.lerp(b, t)
+		rs.fragment(x, y, sa.z+(sb.z-sa.z)*t, true, &v.varying)
+	}
+}
+
+// point rasterizes a square point of the device's point size.
+func (rs *rasterState) point(v vertex) {
+	if len(clipPolygon([]vertex{v})) == 0 {
+		return
+	}
+	s := rs.toScreen(v)
+	size := rs.c.d.PointSize
+	if size < 1 {
+		size = 1
+	}
+	min := image.Pt(int(math.Floor(s.x-float64(size)/2+0.5)), int(math.Floor(s.y-float64(size)/2+0.5)))
+	box := image.Rectangle{min, min.Add(image.Pt(size, size))}.Intersect(rs.r)
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			rs.fragment(x, y, s.z, true, &v.varying)
+		}
+	}
+}
+
+// fragment shades and tests a single fragment, and writes it to the canvas if
+// it passes.
+func (rs *rasterState) fragment(x, y int, z float64, front bool, varying *[nVarying]float64) {
+	c := rs.c
+	st := rs.state
+	i := c.index(x, y)
+
+	// Stencil test.
+	stencil := st.StencilFront
+	if !front {
+		stencil = st.StencilBack
+	}
+	if st.StencilTest {
+		ref := uint8(stencil.Reference) & uint8(stencil.ReadMask)
+		val := c.stencil[i] & uint8(stencil.ReadMask)
+		if !compare(stencil.Cmp, float64(ref), float64(val)) {
+			c.stencilOp(i, stencil, stencil.Fail)
+			return
+		}
+	}
+
+	// Depth test.
+	if st.DepthTest && !compare(st.DepthCmp, z, float64(c.depth[i])) {
+		if st.StencilTest {
+			c.stencilOp(i, stencil, stencil.DepthFail)
+		}
+		return
+	}
+
+	// Shade the fragment.
+	f := rs.frag
+	f.Color = gfx.Color{
+		R: float32(varying[vColor]),
+		G: float32(varying[vColor+1]),
+		B: float32(varying[vColor+2]),
+		A: float32(varying[vColor+3]),
+	}
+	f.TexCoord = gfx.TexCoord{U: float32(varying[vTexCoord]), V: float32(varying[vTexCoord+1])}
+	f.Vertex = gfx.Vec3{X: float32(varying[vVertex]), Y: float32(varying[vVertex+1]), Z: float32(varying[vVertex+2])}
+	col, discard := rs.shader(f)
+	if discard {
+		return
+	}
+	switch st.AlphaMode {
+	case gfx.BinaryAlpha, gfx.AlphaToCoverage:
+		if col.A < 0.5 {
+			return
+		}
+	}
+
+	if st.StencilTest {
+		c.stencilOp(i, stencil, stencil.DepthPass)
+	}
+	if st.DepthTest && st.DepthWrite {
+		c.depth[i] = float32(z)
+	}
+
+	// Blend and write the color.
+	dst := c.color[i]
+	if st.AlphaMode == gfx.AlphaBlend {
+		col = gfx.Color{
+			R: col.R*col.A + dst.R*(1-col.A),
+			G: col.G*col.A + dst.G*(1-col.A),
+			B: col.B*col.A + dst.B*(1-col.A),
+			A: col.A + dst.A*(1-col.A),
+		}
+	}
+	if st.WriteRed {
+		dst.R = col.R
+	}
+	if st.WriteGreen {
+		dst.G = col.G
+	}
+	if st.WriteBlue {
+		dst.B = col.B
+	}
+	if st.WriteAlpha {
+		dst.A = col.A
+	}
+	c.color[i] = dst
+}
+
+// compare reports whether the incoming value a passes the comparison against
+// the stored value b.
+func compare(cmp gfx.Cmp, a, b float64) bool {
+	switch cmp {
+	case gfx.Never:
+		return false
+	case gfx.Less:
+		return a < b
+	case gfx.LessOrEqual:
+		return a <= b
+	case gfx.Greater:
+		return a > b
+	case gfx.GreaterOrEqual:
+		return a >= b
+	case gfx.Equal:
+		return a == b
+	case gfx.NotEqual:
+		return a != b
+	default:
+		return true
+	}
+}
+
+// stencilOp applies the stencil operation to the stencil buffer at index i.
+func (c *canvas) stencilOp(i int, s gfx.StencilState, op gfx.StencilOp) {
+	old := c.stencil[i]
+	v := old
+	switch op {
+	case gfx.SKeep:
+		return
+	case gfx.SZero:
+		v = 0
+	case gfx.SReplace:
+		v = uint8(s.Reference)
+	case gfx.SIncr:
+		if v < 0xFF {
+			v++
+		}
+	case gfx.SIncrWrap:
+		v++
+	case gfx.SDecr:
+		if v > 0 {
+			v--
+		}
+	case gfx.SDecrWrap:
+		v--
+	case gfx.SInvert:
+		v = ^v
+	}
+	mask := uint8(s.WriteMask)
+	c.stencil[i] = (old &^ mask) | (v & mask)
+}
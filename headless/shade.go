@@ -0,0 +1,165 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package headless
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"azul3d.org/engine/gfx"
+)
+
+// Fragment is the input to a FragmentShader: the vertex data of the mesh being
+// drawn, interpolated at the fragment.
+type Fragment struct {
+	// The vertex color, or opaque white if the mesh has no colors.
+	Color gfx.Color
+
+	// The first set of texture coordinates, if the mesh has any.
+	TexCoord gfx.TexCoord
+
+	// The vertex position in the object's local space.
+	Vertex gfx.Vec3
+
+	// The inputs of the gfx.Shader the object is drawn with.
+	Inputs map[string]interface{}
+
+	textures []*gfx.Texture
+	samplers []*texture
+}
+
+// Sample samples the object's i'th texture at the given coordinates, using
+// the texture's filter and wrap modes. Transparent black is returned if the
+// object has no such texture.
+func (f *Fragment) Sample(i int, tc gfx.TexCoord) gfx.Color {
+	if i >= len(f.textures) {
+		return gfx.Color{}
+	}
+	if f.samplers[i] == nil {
+		return gfx.Color{}
+	}
+	return f.samplers[i].sample(f.textures[i], tc)
+}
+
+// FragmentShader computes the color of a fragment, standing in for a GLSL
+// shader. If discard is true the fragment is not written.
+type FragmentShader func(f *Fragment) (c gfx.Color, discard bool)
+
+// DefaultShader is the FragmentShader used for shaders that have none
+// registered. It multiplies the vertex color with the first texture, as the
+// fixed-function pipeline would.
+func DefaultShader(f *Fragment) (gfx.Color, bool) {
+	c := f.Color
+	if len(f.textures) > 0 {
+		t := f.Sample(0, f.TexCoord)
+		c = gfx.Color{R: c.R * t.R, G: c.G * t.G, B: c.B * t.B, A: c.A * t.A}
+	}
+	return c, false
+}
+
+// TextureShader colors fragments with the first texture alone, ignoring the
+// vertex color, as the GLSL shaders of the textured examples do.
+func TextureShader(f *Fragment) (gfx.Color, bool) {
+	return f.Sample(0, f.TexCoord), false
+}
+
+// ColorShader colors fragments with the vertex color alone, ignoring any
+// textures.
+func ColorShader(f *Fragment) (gfx.Color, bool) {
+	return f.Color, false
+}
+
+// texture is the loaded, non-premultiplied copy of a gfx.Texture's image.
+type texture struct {
+	img *image.NRGBA
+}
+
+// newTexture converts src for sampling.
+func newTexture(src image.Image) *texture {
+	b := src.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), src, b.Min, draw.Src)
+	return &texture{img: img}
+}
+
+// texel returns the texel at x, y wrapped according to t.
+func (tex *texture) texel(t *gfx.Texture, x, y int) gfx.Color {
+	w, h := tex.img.Rect.Dx(), tex.img.Rect.Dy()
+	var ok bool
+	if x, ok = wrap(t.WrapU, x, w); !ok {
+		return t.BorderColor
+	}
+	if y, ok = wrap(t.WrapV, y, h); !ok {
+		return t.BorderColor
+	}
+	c := tex.img.NRGBAAt(x, y)
+	return gfx.Color{
+		R: float32(c.R) / 255,
+		G: float32(c.G) / 255,
+		B: float32(c.B) / 255,
+		A: float32(c.A) / 255,
+	}
+}
+
+// sample samples the texture at tc. Mipmapped filters are treated as their
+// non-mipmapped equivalent.
+func (tex *texture) sample(t *gfx.Texture, tc gfx.TexCoord) gfx.Color {
+	w, h := tex.img.Rect.Dx(), tex.img.Rect.Dy()
+	if w == 0 || h == 0 {
+		return gfx.Color{}
+	}
+	x := float64(tc.U)*float64(w) - 0.5
+	y := float64(tc.V)*float64(h) - 0.5
+	switch t.MagFilter {
+	case gfx.Nearest, gfx.NearestMipmapNearest, gfx.NearestMipmapLinear:
+		return tex.texel(t, int(math.Floor(x+0.5)), int(math.Floor(y+0.5)))
+	}
+
+	// Bilinear filtering.
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := float32(x-x0), float32(y-y0)
+	ix, iy := int(x0), int(y0)
+	c00 := tex.texel(t, ix, iy)
+	c10 := tex.texel(t, ix+1, iy)
+	c01 := tex.texel(t, ix, iy+1)
+	c11 := tex.texel(t, ix+1, iy+1)
+	lerp := func(a, b, c, d float32) float32 {
+		top := a + (b-a)*fx
+		bottom := c + (d-c)*fx
+		return top + (bottom-top)*fy
+	}
+	return gfx.Color{
+		R: lerp(c00.R, c10.R, c01.R, c11.R),
+		G: lerp(c00.G, c10.G, c01.G, c11.G),
+		B: lerp(c00.B, c10.B, c01.B, c11.B),
+		A: lerp(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+// wrap wraps the texel coordinate v into [0, n) according to the wrap mode.
+// It returns false if the border color should be used instead.
+func wrap(mode gfx.TexWrap, v, n int) (int, bool) {
+	switch mode {
+	case gfx.Clamp:
+		if v < 0 {
+			return 0, true
+		}
+		if v >= n {
+			return n - 1, true
+		}
+		return v, true
+	case gfx.BorderColor:
+		return v, v >= 0 && v < n
+	case gfx.Mirror:
+		v = ((v % (2 * n)) + 2*n) % (2 * n)
+		if v >= n {
+			v = 2*n - 1 - v
+		}
+		return v, true
+	default:
+		return ((v % n) + n) % n, true
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package headless
+
+import (
+	"image"
+	"runtime"
+	"sync"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/window"
+	"azul3d.org/engine/keyboard"
+	"azul3d.org/engine/mouse"
+)
+
+// Window is a window.Window that has no actual window. Events are only ever
+// sent to it explicitly, using Send.
+type Window struct {
+	sync.Mutex
+	props     *window.Props
+	keyboard  *keyboard.Watcher
+	mouse     *mouse.Watcher
+	clipboard string
+	notify    map[chan<- window.Event]window.EventMask
+	closed    chan struct{}
+}
+
+// NewWindow returns a new window with the given properties, if props is nil
+// window.DefaultProps is used.
+func NewWindow(props *window.Props) *Window {
+	if props == nil {
+		props = window.DefaultProps
+	}
+	p := *props
+	return &Window{
+		props:    &p,
+		keyboard: keyboard.NewWatcher(),
+		mouse:    mouse.NewWatcher(),
+		notify:   make(map[chan<- window.Event]window.EventMask),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Props implements the window.Window interface.
+func (w *Window) Props() *window.Props {
+	w.Lock()
+	defer w.Unlock()
+	p := *w.props
+	return &p
+}
+
+// Request implements the window.Window interface. The properties are simply
+// stored.
+func (w *Window) Request(p *window.Props) {
+	w.Lock()
+	cpy := *p
+	w.props = &cpy
+	w.Unlock()
+}
+
+// Keyboard implements the window.Window interface.
+func (w *Window) Keyboard() *keyboard.Watcher {
+	return w.keyboard
+}
+
+// Mouse implements the window.Window interface.
+func (w *Window) Mouse() *mouse.Watcher {
+	return w.mouse
+}
+
+// SetClipboard implements the window.Window interface.
+func (w *Window) SetClipboard(clipboard string) {
+	w.Lock()
+	w.clipboard = clipboard
+	w.Unlock()
+}
+
+// Clipboard implements the window.Window interface.
+func (w *Window) Clipboard() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.clipboard
+}
+
+// Notify implements the window.Window interface.
+func (w *Window) Notify(ch chan<- window.Event, m window.EventMask) {
+	w.Lock()
+	defer w.Unlock()
+	if m == window.NoEvents {
+		delete(w.notify, ch)
+		return
+	}
+	w.notify[ch] = m
+}
+
+// Close implements the window.Window interface.
+func (w *Window) Close() {
+	w.Lock()
+	defer w.Unlock()
+	select {
+	case <-w.closed:
+	default:
+		close(w.closed)
+	}
+}
+
+// Send sends the event to each channel registered through Notify for its type
+// of event. Like a real window, events are dropped if a channel is full.
+func (w *Window) Send(ev window.Event) {
+	var m window.EventMask
+	switch e := ev.(type) {
+	case window.Close:
+		m = window.CloseEvents
+	case window.CursorMoved:
+		m = window.CursorMovedEvents
+	case window.FramebufferResized:
+		m = window.FramebufferResizedEvents
+	case keyboard.Typed:
+		m = window.KeyboardTypedEvents
+	case keyboard.ButtonEvent:
+		w.keyboard.SetState(e.Key, e.State)
+		m = window.KeyboardButtonEvents
+	case mouse.ButtonEvent:
+		w.mouse.SetState(e.Button, e.State)
+		m = window.MouseEvents
+	case mouse.Scrolled:
+		m = window.MouseScrolledEvents
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	for ch, mask := range w.notify {
+		if mask&m == 0 {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Run runs gfxLoop with a new Window and Device created using the given
+// properties (window.DefaultProps if nil), until it has rendered the given
+// number of frames, the window is closed or gfxLoop returns. The last rendered
+// frame, if any, is returned.
+//
+// The render loops of the examples never return, so once Run stops, the call
+// to Render that stopped it ends the goroutine it was called from using
+// runtime.Goexit. When gfxLoop renders from the goroutine it is run in, that
+// is the goroutine that exits, running the deferred calls of gfxLoop.
+func Run(gfxLoop func(w window.Window, d gfx.Device), props *window.Props, frames int) *image.RGBA {
+	w := NewWindow(props)
+	width, height := w.props.Size()
+	d := New(image.Rect(0, 0, width, height), w.props.Precision())
+	return RunDevice(gfxLoop, w, d, frames)
+}
+
+// RunDevice is like Run, but uses the given window and device.
+func RunDevice(gfxLoop func(w window.Window, d gfx.Device), w *Window, d *Device, frames int) *image.RGBA {
+	var (
+		lock     sync.Mutex
+		last     *image.RGBA
+		rendered int
+		done     = make(chan struct{}) // Closed once gfxLoop returns.
+		stop     = make(chan struct{}) // Closed once Run is to stop.
+		stopOnce sync.Once
+	)
+	onRender := d.OnRender
+	d.OnRender = func(frame *image.RGBA) {
+		if onRender != nil {
+			onRender(frame)
+		}
+		lock.Lock()
+		last = frame
+		rendered++
+		n := rendered
+		lock.Unlock()
+
+		select {
+		case <-w.closed:
+		default:
+			if n < frames {
+				return
+			}
+		}
+		stopOnce.Do(func() { close(stop) })
+		runtime.Goexit() // Keep gfxLoop from rendering, see Run.
+	}
+	go func() {
+		defer close(done)
+		gfxLoop(w, d)
+	}()
+	select {
+	case <-done:
+	case <-stop:
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	return last
+}
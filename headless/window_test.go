@@ -0,0 +1,45 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package headless
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/gfx/window"
+)
+
+// TestRunExits checks that a render loop that never returns is ended once Run
+// stops, running its deferred calls.
+func TestRunExits(t *testing.T) {
+	exited := make(chan struct{})
+	var rendered int
+	gfxLoop := func(w window.Window, d gfx.Device) {
+		defer close(exited)
+		for {
+			d.Clear(d.Bounds(), gfx.Color{R: 1, A: 1})
+			d.Render()
+			rendered++
+		}
+	}
+	props := window.NewProps()
+	props.SetSize(4, 4)
+	frame := Run(gfxLoop, props, 3)
+	if frame == nil || frame.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("got frame %v, want a 4x4 one", frame)
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("render loop still running after Run returned")
+	}
+	// Render returned for every frame but the last.
+	if rendered != 2 {
+		t.Errorf("Render returned %d times, want 2", rendered)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package reload watches example asset files on disk and reloads the shaders
+// and textures using them whenever they change.
+//
+// This helper package is not an important concept to any of the examples, it
+// just lets assets be edited while an example is running.
+package reload
+
+import (
+	"image"
+	_ "image/png" // Add PNG decoder for reloaded textures.
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/abs"
+)
+
+// Interval is the interval at which watched files are checked for changes.
+var Interval = 500 * time.Millisecond
+
+// file is a single file being watched.
+type file struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// changed stats the file and reports whether it has changed since the last
+// call.
+func (f *file) changed() bool {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		// The file may be mid-write, try again later.
+		return false
+	}
+	changed := !fi.ModTime().Equal(f.modTime) || fi.Size() != f.size
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+	return changed
+}
+
+// asset is a set of files that together make up one shader or texture.
+type asset struct {
+	name  string
+	files []*file
+
+	// reload is called from the watching goroutine once any file has changed.
+	// It returns a function to be called on the render goroutine, or an error.
+	reload func() (apply func(), err error)
+}
+
+// Watcher watches asset files and reloads the shaders and textures using them.
+// Reloaded assets are only swapped in when Update is called, which should be
+// done once per frame from the goroutine rendering with them.
+type Watcher struct {
+	d         gfx.Device
+	dir       string
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	sync.Mutex
+	assets  []*asset
+	pending []func()
+
+	// Shaders being compiled by the device, see Update.
+	compiling []compile
+}
+
+// compile is a shader being test-compiled before it replaces a live one.
+type compile struct {
+	name      string
+	live, tmp *gfx.Shader
+	done      chan *gfx.Shader
+}
+
+// New returns a new watcher that loads shaders using the given device. If the
+// examples directory cannot be found on disk there is nothing to watch and the
+// watcher does nothing.
+func New(d gfx.Device) *Watcher {
+	w := &Watcher{
+		d:    d,
+		stop: make(chan struct{}),
+	}
+	dir, err := abs.Dir()
+	if err != nil {
+		log.Println("reload: not watching assets:", err)
+		return w
+	}
+	w.dir = dir
+	go w.run()
+	return w
+}
+
+// watch starts watching the named asset files.
+func (w *Watcher) watch(a *asset, names ...string) {
+	if len(w.dir) == 0 {
+		return
+	}
+	for _, name := range names {
+		path, _ := abs.Find(name)
+		f := &file{path: path}
+		f.changed() // Record the current state.
+		a.files = append(a.files, f)
+	}
+	w.Lock()
+	w.assets = append(w.assets, a)
+	w.Unlock()
+}
+
+// Shader watches the GLSL sources of the named shader (see abs.OpenShader)
+// and reloads s when they change. A shader that fails to compile is logged
+// and s is left untouched.
+func (w *Watcher) Shader(s *gfx.Shader, name string) {
+	a := &asset{name: name}
+	a.reload = func() (func(), error) {
+		tmp, err := abs.OpenShader(name)
+		if err != nil {
+			return nil, err
+		}
+		tmp.Name = s.Name
+		tmp.KeepDataOnLoad = true
+		return func() {
+			c := compile{name: name, live: s, tmp: tmp, done: make(chan *gfx.Shader, 1)}
+			w.d.LoadShader(tmp, c.done)
+			w.compiling = append(w.compiling, c)
+		}, nil
+	}
+	w.watch(a, name+".vert", name+".frag")
+}
+
+// Texture watches the named image file (see abs.OpenTexture) and reloads t
+// when it changes.
+func (w *Watcher) Texture(t *gfx.Texture, name string) {
+	a := &asset{name: name}
+	a.reload = func() (func(), error) {
+		f, err := os.Open(a.files[0].path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			t.Lock()
+			t.Source = img
+			t.Bounds = img.Bounds()
+			t.Loaded = false
+			t.Unlock()
+			log.Println("reload:", name)
+		}, nil
+	}
+	w.watch(a, name)
+}
+
+// Update swaps in any reloaded assets. It must be called from the goroutine
+// that renders with them, typically once per frame before drawing.
+func (w *Watcher) Update() {
+	w.Lock()
+	pending := w.pending
+	w.pending = nil
+	w.Unlock()
+	for _, apply := range pending {
+		apply()
+	}
+
+	// Swap in shaders whose test-compile has finished.
+	n := 0
+	for _, c := range w.compiling {
+		select {
+		case <-c.done:
+		default:
+			w.compiling[n] = c
+			n++
+			continue
+		}
+		// The test-compiled shader is only needed for its result, copy that
+		// and free it (and its program on the GPU) before going on.
+		c.tmp.RLock()
+		errMsg := string(c.tmp.Error)
+		glsl := &gfx.GLSLSources{
+			Vertex:   append([]byte(nil), c.tmp.GLSL.Vertex...),
+			Fragment: append([]byte(nil), c.tmp.GLSL.Fragment...),
+		}
+		c.tmp.RUnlock()
+		c.tmp.Destroy()
+
+		if len(errMsg) > 0 {
+			log.Printf("reload: %s: %s\n", c.name, errMsg)
+			continue
+		}
+		c.live.Lock()
+		c.live.GLSL = glsl
+		c.live.Loaded = false
+		c.live.Unlock()
+		log.Println("reload:", c.name)
+	}
+	w.compiling = w.compiling[:n]
+}
+
+// Close stops watching for changes, and frees the shaders still being
+// test-compiled. Like Update it must be called from the goroutine rendering
+// with the assets, and it may be called more than once.
+func (w *Watcher) Close() {
+	w.closeOnce.Do(func() {
+		if len(w.dir) > 0 {
+			close(w.stop)
+		}
+		for _, c := range w.compiling {
+			c.tmp.Destroy()
+		}
+		w.compiling = nil
+	})
+}
+
+// run polls the watched files until the watcher is closed.
+func (w *Watcher) run() {
+	t := time.NewTicker(Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+		}
+
+		w.Lock()
+		assets := w.assets
+		w.Unlock()
+		for _, a := range assets {
+			changed := false
+			for _, f := range a.files {
+				if f.changed() {
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+			apply, err := a.reload()
+			if err != nil {
+				log.Printf("reload: %s: %v\n", a.name, err)
+				continue
+			}
+			w.Lock()
+			w.pending = append(w.pending, apply)
+			w.Unlock()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import "image/color"
+
+// Layer is a layer of a map: a *TileLayer, *ObjectGroup, *ImageLayer or
+// *Group.
+type Layer interface {
+	// Info returns the attributes of the layer.
+	Info() *LayerInfo
+}
+
+// LayerInfo holds the attributes that every kind of layer has. They apply to
+// the layer alone, see Composite for how they combine with those of the
+// groups it is in.
+type LayerInfo struct {
+	Name string
+
+	Opacity float64
+	Visible bool
+
+	// OffsetX and OffsetY are the offset of the layer in pixels.
+	OffsetX, OffsetY float64
+
+	// Tint is the color the layer is multiplied with, white if not set.
+	Tint color.NRGBA
+
+	// ParallaxX and ParallaxY are how fast the layer scrolls relative to the
+	// camera along each axis, one meaning as fast as the map.
+	ParallaxX, ParallaxY float64
+
+	Properties Properties
+
+	// Parent is the group the layer is in, nil for the top-level layers.
+	Parent *Group
+}
+
+// Info returns the attributes of the layer.
+func (i *LayerInfo) Info() *LayerInfo { return i }
+
+// Composite is the attributes of a layer combined with those of the groups
+// it is in, which is how Tiled draws the layer.
+type Composite struct {
+	// Visible is whether the layer and all of its groups are visible.
+	Visible bool
+
+	// Opacity and Tint are the product of those of the layer and its groups.
+	Opacity float64
+	Tint    color.NRGBA
+
+	// Offset is the sum of the offsets of the layer and its groups, in
+	// pixels.
+	Offset Vec2
+
+	// Parallax is the product of the parallax factors of the layer and its
+	// groups.
+	Parallax Vec2
+}
+
+// Composite returns the attributes of the layer combined with those of the
+// groups it is in.
+func (i *LayerInfo) Composite() Composite {
+	c := Composite{
+		Visible:  i.Visible,
+		Opacity:  i.Opacity,
+		Tint:     i.Tint,
+		Offset:   Vec2{i.OffsetX, i.OffsetY},
+		Parallax: Vec2{i.ParallaxX, i.ParallaxY},
+	}
+	for g := i.Parent; g != nil; g = g.Parent {
+		c.Visible = c.Visible && g.Visible
+		c.Opacity *= g.Opacity
+		c.Tint = color.NRGBA{
+			R: uint8(uint(c.Tint.R) * uint(g.Tint.R) / 255),
+			G: uint8(uint(c.Tint.G) * uint(g.Tint.G) / 255),
+			B: uint8(uint(c.Tint.B) * uint(g.Tint.B) / 255),
+			A: uint8(uint(c.Tint.A) * uint(g.Tint.A) / 255),
+		}
+		c.Offset.X += g.OffsetX
+		c.Offset.Y += g.OffsetY
+		c.Parallax.X *= g.ParallaxX
+		c.Parallax.Y *= g.ParallaxY
+	}
+	return c
+}
+
+// Shift returns the offset in pixels at which the layer is drawn when the
+// center of the view is at the given position on the map: its offset, plus
+// how much less (or more) than the map it has scrolled relative to the
+// parallax origin.
+func (c Composite) Shift(origin, center Vec2) Vec2 {
+	return Vec2{
+		X: c.Offset.X + (1-c.Parallax.X)*(center.X-origin.X),
+		Y: c.Offset.Y + (1-c.Parallax.Y)*(center.Y-origin.Y),
+	}
+}
+
+// Group is a group layer, which holds other layers.
+type Group struct {
+	LayerInfo
+
+	// Layers are the layers in the group, from the bottom one up.
+	Layers []Layer
+}
+
+// ImageLayer is a layer displaying a single image, with its top-left corner
+// at the layer's offset.
+type ImageLayer struct {
+	LayerInfo
+
+	// Image is the image of the layer, nil if it has none.
+	Image *Image
+}
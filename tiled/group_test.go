@@ -0,0 +1,105 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"image/color"
+	"testing"
+)
+
+// compositeTests are the composite attributes of the layers of layers.tmx,
+// and where they are drawn with the center of the view at the parallax origin
+// (160, 80) and at (360, 180).
+var compositeTests = []struct {
+	name          string
+	c             Composite
+	atOrigin, off Vec2
+}{
+	{
+		"sky",
+		Composite{Visible: true, Opacity: 0.6, Tint: white, Parallax: Vec2{0.5, 0.5}},
+		Vec2{0, 0}, Vec2{100, 50},
+	},
+	{
+		"ground",
+		Composite{Visible: true, Opacity: 1, Tint: color.NRGBA{R: 255, G: 208, B: 208, A: 255}, Offset: Vec2{16, 8}, Parallax: Vec2{1, 1}},
+		Vec2{16, 8}, Vec2{16, 8},
+	},
+	{
+		"hidden",
+		Composite{Visible: false, Opacity: 1, Tint: color.NRGBA{R: 255, G: 208, B: 208, A: 255}, Offset: Vec2{16, 8}, Parallax: Vec2{1, 1}},
+		Vec2{16, 8}, Vec2{16, 8},
+	},
+	{
+		// Within the nested decor group, within world.
+		"faded",
+		Composite{Visible: true, Opacity: 0.4, Tint: color.NRGBA{R: 128, G: 208, B: 208, A: 255}, Offset: Vec2{12, 10}, Parallax: Vec2{0.5, 1}},
+		Vec2{12, 10}, Vec2{112, 10},
+	},
+	{
+		"markers",
+		Composite{Visible: true, Opacity: 1, Tint: color.NRGBA{R: 255, G: 208, B: 208, A: 255}, Offset: Vec2{16, 8}, Parallax: Vec2{1, 1}},
+		Vec2{16, 8}, Vec2{16, 8},
+	},
+	{
+		// Visible, but within a hidden group.
+		"covered",
+		Composite{Visible: false, Opacity: 1, Tint: white, Parallax: Vec2{1, 1}},
+		Vec2{0, 0}, Vec2{0, 0},
+	},
+	{
+		"foreground",
+		Composite{Visible: true, Opacity: 1, Tint: white, Parallax: Vec2{1.5, 1}},
+		Vec2{0, 0}, Vec2{-100, 0},
+	},
+}
+
+// white is the tint of layers without one.
+var white = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+func TestComposite(t *testing.T) {
+	m, err := Open(testdata, "layers.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Vec2{160, 80}); m.ParallaxOrigin != want {
+		t.Fatalf("parallax origin %v, want %v", m.ParallaxOrigin, want)
+	}
+
+	// The layers of every group, flattened from the bottom one up.
+	var layers []Layer
+	var add func(ls []Layer)
+	add = func(ls []Layer) {
+		for _, l := range ls {
+			if g, ok := l.(*Group); ok {
+				add(g.Layers)
+				continue
+			}
+			layers = append(layers, l)
+		}
+	}
+	add(m.Layers)
+	if len(layers) != len(compositeTests) {
+		t.Fatalf("got %d layers, want %d", len(layers), len(compositeTests))
+	}
+
+	for i, tst := range compositeTests {
+		info := layers[i].Info()
+		if info.Name != tst.name {
+			t.Errorf("layer %d is %q, want %q", i, info.Name, tst.name)
+			continue
+		}
+		c := info.Composite()
+		if c != tst.c {
+			t.Errorf("%s: composite %+v, want %+v", tst.name, c, tst.c)
+		}
+		if got := c.Shift(m.ParallaxOrigin, m.ParallaxOrigin); got != tst.atOrigin {
+			t.Errorf("%s: shifted by %v at the parallax origin, want %v", tst.name, got, tst.atOrigin)
+		}
+		if got := c.Shift(m.ParallaxOrigin, Vec2{360, 180}); got != tst.off {
+			t.Errorf("%s: shifted by %v at 360,180, want %v", tst.name, got, tst.off)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// TileLayer is a layer of tiles on the map's grid.
+type TileLayer struct {
+	LayerInfo
+
+	// X and Y are the position in tiles of the layer's top-left tile, and
+	// Width and Height its size in tiles. For infinite maps they are those of
+	// the area covered by the chunks the layer is stored as, otherwise X and
+	// Y are zero.
+	X, Y, Width, Height int
+
+	// Encoding and Compression are how the tiles were stored in the file, as
+	// written in it (e.g. "base64" and "zlib"). Both are empty for tiles
+	// stored as XML elements.
+	Encoding, Compression string
+
+	// Tiles holds the tile at each position of the layer, row by row, zero
+	// meaning no tile.
+	Tiles []GID
+}
+
+// Bounds returns the area of the map covered by the layer, in tiles.
+func (l *TileLayer) Bounds() image.Rectangle {
+	return image.Rect(l.X, l.Y, l.X+l.Width, l.Y+l.Height)
+}
+
+// At returns the tile at x, y, or zero if there is none.
+func (l *TileLayer) At(x, y int) GID {
+	x, y = x-l.X, y-l.Y
+	if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+		return 0
+	}
+	return l.Tiles[y*l.Width+x]
+}
+
+// Set sets the tile at x, y, and reports whether the position is within the
+// layer. Layers do not grow, even those of infinite maps.
+func (l *TileLayer) Set(x, y int, gid GID) bool {
+	x, y = x-l.X, y-l.Y
+	if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+		return false
+	}
+	l.Tiles[y*l.Width+x] = gid
+	return true
+}
+
+// Fill sets the tile at x, y and every tile connected to it by its edges that
+// is the same as it to gid, like a paint bucket. It returns the positions of
+// the tiles that were set.
+func (l *TileLayer) Fill(x, y int, gid GID) []image.Point {
+	if !image.Pt(x, y).In(l.Bounds()) {
+		return nil
+	}
+	old := l.At(x, y)
+	if old == gid {
+		return nil
+	}
+	var (
+		filled []image.Point
+		stack  = []image.Point{{x, y}}
+	)
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !p.In(l.Bounds()) || l.At(p.X, p.Y) != old {
+			continue
+		}
+		l.Set(p.X, p.Y, gid)
+		filled = append(filled, p)
+		stack = append(stack, image.Pt(p.X+1, p.Y), image.Pt(p.X-1, p.Y), image.Pt(p.X, p.Y+1), image.Pt(p.X, p.Y-1))
+	}
+	return filled
+}
+
+// decodeTiles decodes n tiles stored with the given encoding and compression
+// in the text of a data element, or as its tile elements if the encoding is
+// empty.
+func decodeTiles(encoding, compression, text string, elems []xmlDataTile, n int) ([]GID, error) {
+	var tiles []GID
+	switch encoding {
+	case "":
+		tiles = make([]GID, len(elems))
+		for i, e := range elems {
+			tiles[i] = e.GID
+		}
+
+	case "csv":
+		for _, f := range strings.Split(text, ",") {
+			f = strings.TrimSpace(f)
+			if len(f) == 0 {
+				continue
+			}
+			v, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CSV tile %q", f)
+			}
+			tiles = append(tiles, GID(v))
+		}
+
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = decompress(compression, data); err != nil {
+			return nil, err
+		}
+		if len(data)%4 != 0 {
+			return nil, fmt.Errorf("tile data is %d bytes, not a multiple of 4", len(data))
+		}
+		tiles = make([]GID, len(data)/4)
+		for i := range tiles {
+			tiles[i] = GID(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	if len(tiles) != n {
+		return nil, fmt.Errorf("found %d tiles, want %d", len(tiles), n)
+	}
+	return tiles, nil
+}
+
+// decompress decompresses data compressed with the given method, an empty
+// string meaning none. Tiled can also compress layers with zstd, which is
+// reported as unsupported since the standard library has no zstd package.
+func decompress(compression string, data []byte) ([]byte, error) {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch compression {
+	case "":
+		return data, nil
+	case "zlib":
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
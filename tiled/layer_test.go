@@ -0,0 +1,145 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testdata holds the example maps, including a map per layer encoding in
+// encodings/, each one holding the same tiles.
+var testdata = os.DirFS("../azul3d_tmx/data")
+
+// encodingTests are the layer encodings of the maps in encodings/, each stored
+// in a finite and an infinite map.
+var encodingTests = []struct {
+	name                  string
+	encoding, compression string
+	err                   string // Error decoding the map, if any.
+}{
+	{name: "csv", encoding: "csv"},
+	{name: "xml"},
+	{name: "base64", encoding: "base64"},
+	{name: "gzip", encoding: "base64", compression: "gzip"},
+	{name: "zlib", encoding: "base64", compression: "zlib"},
+	{name: "zstd", encoding: "base64", compression: "zstd", err: `unsupported compression "zstd"`},
+}
+
+// encodingMap returns the name of the map in encodings/ with the given base
+// name.
+func encodingMap(name string, infinite bool) string {
+	if infinite {
+		return "encodings/" + name + "_infinite.tmx"
+	}
+	return "encodings/" + name + ".tmx"
+}
+
+// sameTiles returns an error describing the first difference between the
+// tiles of the layers, nil if they hold the same tiles.
+func sameTiles(got, want *TileLayer) error {
+	b := got.Bounds().Union(want.Bounds())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if g, w := got.At(x, y), want.At(x, y); g != w {
+				return fmt.Errorf("tile at %d,%d is %d, want %d", x, y, g, w)
+			}
+		}
+	}
+	return nil
+}
+
+func TestDecodeEncodings(t *testing.T) {
+	for _, infinite := range []bool{false, true} {
+		want, err := Open(testdata, encodingMap("csv", infinite))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tst := range encodingTests {
+			name := encodingMap(tst.name, infinite)
+			m, err := Open(testdata, name)
+			if tst.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tst.err) {
+					t.Errorf("%s: got error %v, want %q", name, err, tst.err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if m.Infinite != infinite || len(m.TileLayers) != len(want.TileLayers) {
+				t.Errorf("%s: infinite %v with %d tile layers, want %v with %d", name, m.Infinite, len(m.TileLayers), infinite, len(want.TileLayers))
+				continue
+			}
+			for i, l := range m.TileLayers {
+				if l.Encoding != tst.encoding || l.Compression != tst.compression {
+					t.Errorf("%s: layer %q encoding %q compression %q, want %q and %q", name, l.Name, l.Encoding, l.Compression, tst.encoding, tst.compression)
+				}
+				if err := sameTiles(l, want.TileLayers[i]); err != nil {
+					t.Errorf("%s: layer %q: %v", name, l.Name, err)
+				}
+			}
+		}
+	}
+}
+
+func TestRewriteEncodings(t *testing.T) {
+	for _, infinite := range []bool{false, true} {
+		for _, tst := range encodingTests {
+			if tst.err != "" {
+				continue
+			}
+			name := encodingMap(tst.name, infinite)
+			src, err := fs.ReadFile(testdata, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := Open(testdata, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Change some tiles, including their flip flags.
+			l := m.TileLayers[0]
+			b := l.Bounds()
+			l.Set(b.Min.X, b.Min.Y, 5|FlipHorizontal)
+			l.Set(b.Max.X-1, b.Max.Y-1, 0)
+			l.Set(b.Min.X+3, b.Min.Y+1, 7|FlipDiagonal|FlipVertical)
+
+			out, err := m.Rewrite(src)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			got, err := Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Errorf("%s: decoding the rewritten map: %v", name, err)
+				continue
+			}
+			for i, gl := range got.TileLayers {
+				wl := m.TileLayers[i]
+				if gl.Encoding != wl.Encoding || gl.Compression != wl.Compression {
+					t.Errorf("%s: layer %q rewritten with encoding %q compression %q, want %q and %q", name, gl.Name, gl.Encoding, gl.Compression, wl.Encoding, wl.Compression)
+				}
+				if err := sameTiles(gl, wl); err != nil {
+					t.Errorf("%s: rewritten layer %q: %v", name, gl.Name, err)
+				}
+			}
+
+			// Rewriting the map once more changes nothing.
+			again, err := got.Rewrite(out)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			} else if !bytes.Equal(again, out) {
+				t.Errorf("%s: rewriting the rewritten map changed it", name)
+			}
+		}
+	}
+}
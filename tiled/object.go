@@ -0,0 +1,132 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"image/color"
+	"math"
+)
+
+// ObjectGroup is an object layer: a group of objects placed freely on the map.
+type ObjectGroup struct {
+	LayerInfo
+
+	// Color is the color the objects are displayed with in Tiled, transparent
+	// if not set.
+	Color color.NRGBA
+
+	// DrawOrder is either "topdown" (objects are drawn sorted by their Y
+	// coordinate) or "index" (in the order they appear).
+	DrawOrder string
+
+	Objects []*Object
+}
+
+// Shape is the shape of an object.
+type Shape int
+
+const (
+	// Rectangle is a Width*Height rectangle with its top-left corner at X, Y.
+	Rectangle Shape = iota
+
+	// Ellipse is an ellipse within the Width*Height rectangle with its
+	// top-left corner at X, Y.
+	Ellipse
+
+	// Point is a single point at X, Y.
+	Point
+
+	// Polygon is a closed shape through Points.
+	Polygon
+
+	// Polyline is an open line through Points.
+	Polyline
+
+	// TileShape is a tile (see Object.GID) scaled to Width*Height, with its
+	// bottom-left corner at X, Y.
+	TileShape
+
+	// Text is a text box, whose text is not decoded, covering the
+	// Width*Height rectangle with its top-left corner at X, Y.
+	Text
+)
+
+// shapeNames are the names of each shape.
+var shapeNames = []string{"rectangle", "ellipse", "point", "polygon", "polyline", "tile", "text"}
+
+// String returns the name of the shape, e.g. "polygon".
+func (s Shape) String() string {
+	if int(s) < len(shapeNames) {
+		return shapeNames[s]
+	}
+	return "unknown"
+}
+
+// Vec2 is a two-dimensional position in pixels.
+type Vec2 struct {
+	X, Y float64
+}
+
+// Object is an object of an object group. Games usually spawn an entity for
+// each object of a type they know.
+type Object struct {
+	// ID is the unique ID of the object within the map.
+	ID int
+
+	Name string
+
+	// Type is the type (or class) of the object. Tile objects without a type
+	// of their own have that of their tile.
+	Type string
+
+	Shape Shape
+
+	// X and Y are the position of the object in pixels. Where it lies on the
+	// shape depends on the shape, see Shape.
+	X, Y float64
+
+	// Width and Height are the size of the object in pixels.
+	Width, Height float64
+
+	// Rotation is the clockwise rotation of the object in degrees around X,
+	// Y.
+	Rotation float64
+
+	// GID is the tile of tile objects, zero for other shapes.
+	GID GID
+
+	Visible bool
+
+	// Points are the points of polygons and polylines, relative to X, Y.
+	Points []Vec2
+
+	// Properties are the properties of the object. Tile objects also have
+	// the properties of their tile that they do not override.
+	Properties Properties
+}
+
+// Place returns the position in pixels on the map of the point p of the
+// object, given relative to its position before rotation, once rotated around
+// its position. On isometric maps shapes are projected onto the map, while
+// tiles and points stay upright, tiles being centered on their position as in
+// Tiled.
+func (m *Map) Place(o *Object, p Vec2) Vec2 {
+	origin := m.Project(Vec2{o.X, o.Y})
+	if m.Orientation == Isometric {
+		switch o.Shape {
+		case TileShape:
+			p.X -= o.Width / 2
+		case Point:
+		default:
+			q := m.Project(Vec2{o.X + p.X, o.Y + p.Y})
+			p = Vec2{q.X - origin.X, q.Y - origin.Y}
+		}
+	}
+	s, c := math.Sincos(o.Rotation * math.Pi / 180)
+	return Vec2{
+		X: origin.X + p.X*c - p.Y*s,
+		Y: origin.Y + p.X*s + p.Y*c,
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// The orientations of a map, as found in its Orientation field.
+const (
+	Orthogonal = "orthogonal"
+	Isometric  = "isometric"
+	Staggered  = "staggered" // Isometric, staggered rows or columns.
+	Hexagonal  = "hexagonal"
+)
+
+// CellBounds returns the bounding box in pixels of the cell of the tile at
+// x, y. Tiles are drawn with their bottom-left corner at the bottom-left
+// corner of their cell, extending upwards and to the right if they are larger
+// than it.
+//
+// Pixel positions are those of the map as drawn by Tiled, with the top-left
+// corner of the map at 0, 0 and Y pointing down.
+func (m *Map) CellBounds(x, y int) image.Rectangle {
+	tw, th := m.TileWidth, m.TileHeight
+	switch m.Orientation {
+	case Isometric:
+		// Cells are diamonds, that of the bottom-left tile of the map
+		// touching the left edge of the map.
+		left := (x-y)*tw/2 + (m.Height-1)*tw/2
+		top := (x + y) * th / 2
+		return image.Rect(left, top, left+tw, top+th)
+
+	case Staggered, Hexagonal:
+		h := m.hex()
+		var left, top int
+		if h.staggerX {
+			left = x * h.columnWidth
+			top = y * (h.tileHeight + h.sideLengthY)
+			if h.shifted(x) {
+				top += h.rowHeight
+			}
+		} else {
+			left = x * (h.tileWidth + h.sideLengthX)
+			top = y * h.rowHeight
+			if h.shifted(y) {
+				left += h.columnWidth
+			}
+		}
+		return image.Rect(left, top, left+h.tileWidth, top+h.tileHeight)
+
+	default:
+		return image.Rect(x*tw, y*th, (x+1)*tw, (y+1)*th)
+	}
+}
+
+// CellOutline returns the outline of the cell of the tile at x, y in pixels,
+// clockwise from its top-left or top corner: a rectangle, a diamond or a
+// hexagon depending on the orientation of the map.
+func (m *Map) CellOutline(x, y int) []Vec2 {
+	r := m.CellBounds(x, y)
+	left, top := float64(r.Min.X), float64(r.Min.Y)
+	right, bottom := float64(r.Max.X), float64(r.Max.Y)
+	cx, cy := (left+right)/2, (top+bottom)/2
+	switch m.Orientation {
+	case Isometric:
+		return []Vec2{{cx, top}, {right, cy}, {cx, bottom}, {left, cy}}
+
+	case Staggered, Hexagonal:
+		// Staggered cells are hexagons with sides of zero length.
+		h := m.hex()
+		if h.staggerX {
+			x0, x1 := left+float64(h.sideOffsetX), right-float64(h.sideOffsetX)
+			return []Vec2{{x0, top}, {x1, top}, {right, cy}, {x1, bottom}, {x0, bottom}, {left, cy}}
+		}
+		y0, y1 := top+float64(h.sideOffsetY), bottom-float64(h.sideOffsetY)
+		return []Vec2{{cx, top}, {right, y0}, {right, y1}, {cx, bottom}, {left, y1}, {left, y0}}
+
+	default:
+		return []Vec2{{left, top}, {right, top}, {right, bottom}, {left, bottom}}
+	}
+}
+
+// TileAt returns the position of the tile whose cell holds the position in
+// pixels on the map, which may be outside of the map.
+func (m *Map) TileAt(p Vec2) image.Point {
+	tw, th := float64(m.TileWidth), float64(m.TileHeight)
+	switch m.Orientation {
+	case Isometric:
+		px := p.X - float64(m.Height)*tw/2
+		return image.Pt(floor(p.Y/th+px/tw), floor(p.Y/th-px/tw))
+	case Staggered:
+		return m.hex().staggeredTileAt(p)
+	case Hexagonal:
+		return m.hex().hexagonalTileAt(p)
+	default:
+		return image.Pt(floor(p.X/tw), floor(p.Y/th))
+	}
+}
+
+// Project returns the position in pixels on the map of a position in object
+// coordinates. They are the same except on isometric maps, where objects are
+// positioned along the axes of the map with both axes measured in units of
+// TileHeight, as Tiled does.
+func (m *Map) Project(p Vec2) Vec2 {
+	if m.Orientation != Isometric {
+		return p
+	}
+	tw, th := float64(m.TileWidth), float64(m.TileHeight)
+	x, y := p.X/th, p.Y/th
+	return Vec2{
+		X: (x-y)*tw/2 + float64(m.Height)*tw/2,
+		Y: (x + y) * th / 2,
+	}
+}
+
+// Bounds returns the area of the map in pixels: that of the cells of the map
+// for finite maps, or of the cells covered by any layer for infinite ones.
+func (m *Map) Bounds() image.Rectangle {
+	area := image.Rect(0, 0, m.Width, m.Height)
+	if m.Infinite {
+		area = image.Rectangle{}
+		for _, l := range m.TileLayers {
+			area = area.Union(l.Bounds())
+		}
+	}
+	if area.Empty() {
+		return image.Rectangle{}
+	}
+
+	// The cells furthest out in any orientation are on the edges of the area.
+	var b image.Rectangle
+	for x := area.Min.X; x < area.Max.X; x++ {
+		b = b.Union(m.CellBounds(x, area.Min.Y)).Union(m.CellBounds(x, area.Max.Y-1))
+	}
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		b = b.Union(m.CellBounds(area.Min.X, y)).Union(m.CellBounds(area.Max.X-1, y))
+	}
+	return b
+}
+
+// SortTiles sorts tile positions in the order Tiled draws them in, such that
+// tiles larger than their cell overlap their neighbours as they should.
+func (m *Map) SortTiles(tiles []image.Point) {
+	var key func(p image.Point) [3]int
+	switch m.Orientation {
+	case Isometric:
+		// Diagonal rows of cells from the top of the map down.
+		key = func(p image.Point) [3]int { return [3]int{p.X + p.Y, p.X, 0} }
+
+	case Staggered, Hexagonal:
+		// Rows from the top down. With staggered columns, the shifted ones
+		// are lower and drawn after the others of the row.
+		h := m.hex()
+		if h.staggerX {
+			key = func(p image.Point) [3]int {
+				shifted := 0
+				if h.shifted(p.X) {
+					shifted = 1
+				}
+				return [3]int{p.Y, shifted, p.X}
+			}
+		} else {
+			key = func(p image.Point) [3]int { return [3]int{p.Y, p.X, 0} }
+		}
+
+	default:
+		dx, dy := 1, 1
+		switch m.RenderOrder {
+		case "right-up":
+			dy = -1
+		case "left-down":
+			dx = -1
+		case "left-up":
+			dx, dy = -1, -1
+		}
+		key = func(p image.Point) [3]int { return [3]int{p.Y * dy, p.X * dx, 0} }
+	}
+	sort.Slice(tiles, func(i, j int) bool {
+		a, b := key(tiles[i]), key(tiles[j])
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+}
+
+// hexLayout holds the dimensions of the cells of a staggered or hexagonal map,
+// as Tiled calculates them.
+type hexLayout struct {
+	// staggerX is whether columns are staggered rather than rows, and
+	// staggerEven whether the even ones are shifted rather than the odd ones.
+	staggerX, staggerEven bool
+
+	// The size of a cell, rounded down to an even number.
+	tileWidth, tileHeight int
+
+	// The length of the sides of a hexagon parallel to the stagger axis, zero
+	// for the other axis and for staggered maps.
+	sideLengthX, sideLengthY int
+
+	// The length of the slanted sides of a hexagon along each axis.
+	sideOffsetX, sideOffsetY int
+
+	// The distance between neighbouring columns and rows.
+	columnWidth, rowHeight int
+}
+
+// hex returns the layout of the cells of a staggered or hexagonal map.
+func (m *Map) hex() hexLayout {
+	h := hexLayout{
+		staggerX:    m.StaggerAxis == "x",
+		staggerEven: m.StaggerIndex == "even",
+		tileWidth:   m.TileWidth &^ 1,
+		tileHeight:  m.TileHeight &^ 1,
+	}
+	if m.Orientation == Hexagonal {
+		if h.staggerX {
+			h.sideLengthX = m.HexSideLength
+		} else {
+			h.sideLengthY = m.HexSideLength
+		}
+	}
+	h.sideOffsetX = (h.tileWidth - h.sideLengthX) / 2
+	h.sideOffsetY = (h.tileHeight - h.sideLengthY) / 2
+	h.columnWidth = h.sideOffsetX + h.sideLengthX
+	h.rowHeight = h.sideOffsetY + h.sideLengthY
+	return h
+}
+
+// shifted tells whether the column or row with the given index along the
+// stagger axis is shifted.
+func (h hexLayout) shifted(i int) bool {
+	return (i&1 != 0) != h.staggerEven
+}
+
+// neighbour returns the position of the tile next to the one at x, y in the
+// given direction (dx, dy each -1 or 1), diagonally across the stagger axis.
+func (h hexLayout) neighbour(x, y, dx, dy int) image.Point {
+	if h.staggerX {
+		// Shifted columns are lower, so their neighbours across the row
+		// boundary are on the next row.
+		if h.shifted(x) == (dy > 0) {
+			return image.Pt(x+dx, y+dy)
+		}
+		return image.Pt(x+dx, y)
+	}
+	// Likewise, shifted rows are further right.
+	if h.shifted(y) == (dx > 0) {
+		return image.Pt(x+dx, y+dy)
+	}
+	return image.Pt(x, y+dy)
+}
+
+// reference returns the tile at the top-left of the block of two cells along
+// the stagger axis holding p, measured in steps of the given size, and the
+// position of p relative to the block.
+func (h hexLayout) reference(p Vec2, stepX, stepY float64) (ref image.Point, rel Vec2) {
+	ref = image.Pt(floor(p.X/stepX), floor(p.Y/stepY))
+	rel = Vec2{X: p.X - float64(ref.X)*stepX, Y: p.Y - float64(ref.Y)*stepY}
+	if h.staggerX {
+		ref.X *= 2
+		if h.staggerEven {
+			ref.X++
+		}
+	} else {
+		ref.Y *= 2
+		if h.staggerEven {
+			ref.Y++
+		}
+	}
+	return ref, rel
+}
+
+// staggeredTileAt returns the tile of a staggered map at p: the block of the
+// grid holding p is that of a tile, unless p is in one of its corners.
+func (h hexLayout) staggeredTileAt(p Vec2) image.Point {
+	if h.staggerX {
+		if h.staggerEven {
+			p.X -= float64(h.sideOffsetX)
+		}
+	} else if h.staggerEven {
+		p.Y -= float64(h.sideOffsetY)
+	}
+	ref, rel := h.reference(p, float64(h.tileWidth), float64(h.tileHeight))
+
+	// The edges of the diamond in the block.
+	y := rel.X * float64(h.tileHeight) / float64(h.tileWidth)
+	offY := float64(h.sideOffsetY)
+	switch {
+	case offY-y > rel.Y:
+		return h.neighbour(ref.X, ref.Y, -1, -1)
+	case -offY+y > rel.Y:
+		return h.neighbour(ref.X, ref.Y, 1, -1)
+	case offY+y < rel.Y:
+		return h.neighbour(ref.X, ref.Y, -1, 1)
+	case 3*offY-y < rel.Y:
+		return h.neighbour(ref.X, ref.Y, 1, 1)
+	}
+	return ref
+}
+
+// hexagonalTileAt returns the tile of a hexagonal map at p: the one with the
+// nearest center among those that may cover the block of the grid holding p.
+func (h hexLayout) hexagonalTileAt(p Vec2) image.Point {
+	if h.staggerX {
+		if h.staggerEven {
+			p.X -= float64(h.tileWidth)
+		} else {
+			p.X -= float64(h.sideOffsetX)
+		}
+	} else {
+		if h.staggerEven {
+			p.Y -= float64(h.tileHeight)
+		} else {
+			p.Y -= float64(h.sideOffsetY)
+		}
+	}
+	ref, rel := h.reference(p, float64(h.columnWidth*2), float64(h.rowHeight*2))
+
+	cw, rh := float64(h.columnWidth), float64(h.rowHeight)
+	var (
+		centers [4]Vec2
+		offsets [4]image.Point
+	)
+	if h.staggerX {
+		left := float64(h.sideLengthX) / 2
+		cx, cy := left+cw, float64(h.tileHeight)/2
+		centers = [4]Vec2{{left, cy}, {cx, cy - rh}, {cx, cy + rh}, {cx + cw, cy}}
+		offsets = [4]image.Point{{0, 0}, {1, -1}, {1, 0}, {2, 0}}
+	} else {
+		top := float64(h.sideLengthY) / 2
+		cx, cy := float64(h.tileWidth)/2, top+rh
+		centers = [4]Vec2{{cx, top}, {cx - cw, cy}, {cx + cw, cy}, {cx, cy + rh}}
+		offsets = [4]image.Point{{0, 0}, {-1, 1}, {0, 1}, {0, 2}}
+	}
+	nearest, min := 0, math.Inf(1)
+	for i, c := range centers {
+		dx, dy := c.X-rel.X, c.Y-rel.Y
+		if d := dx*dx + dy*dy; d < min {
+			nearest, min = i, d
+		}
+	}
+	return ref.Add(offsets[nearest])
+}
+
+// floor returns v rounded down to an integer.
+func floor(v float64) int {
+	return int(math.Floor(v))
+}
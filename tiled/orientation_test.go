@@ -0,0 +1,169 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+// orientationTests hold the cell of a tile of maps of each orientation, as
+// Tiled draws it.
+var orientationTests = []struct {
+	m    Map
+	tile image.Point
+	cell image.Rectangle
+}{
+	{
+		Map{Orientation: Orthogonal, TileWidth: 32, TileHeight: 32, Width: 10, Height: 10},
+		image.Pt(2, 3), image.Rect(64, 96, 96, 128),
+	},
+	{
+		Map{Orientation: Orthogonal, TileWidth: 16, TileHeight: 24, Width: 10, Height: 10, RenderOrder: "left-up"},
+		image.Pt(-1, 2), image.Rect(-16, 48, 0, 72),
+	},
+	{
+		// The bottom-left cell touches the left edge of the map.
+		Map{Orientation: Isometric, TileWidth: 64, TileHeight: 32, Width: 10, Height: 10},
+		image.Pt(2, 3), image.Rect(256, 80, 320, 112),
+	},
+	{
+		Map{Orientation: Isometric, TileWidth: 64, TileHeight: 32, Width: 10, Height: 10},
+		image.Pt(0, 9), image.Rect(0, 144, 64, 176),
+	},
+	{
+		// Odd rows are shifted right by half a cell.
+		Map{Orientation: Staggered, TileWidth: 64, TileHeight: 32, Width: 10, Height: 10, StaggerAxis: "y", StaggerIndex: "odd"},
+		image.Pt(2, 3), image.Rect(160, 48, 224, 80),
+	},
+	{
+		Map{Orientation: Staggered, TileWidth: 64, TileHeight: 32, Width: 10, Height: 10, StaggerAxis: "y", StaggerIndex: "even"},
+		image.Pt(2, 4), image.Rect(160, 64, 224, 96),
+	},
+	{
+		// Even columns are shifted down by half a cell.
+		Map{Orientation: Staggered, TileWidth: 64, TileHeight: 32, Width: 10, Height: 10, StaggerAxis: "x", StaggerIndex: "even"},
+		image.Pt(2, 3), image.Rect(64, 112, 128, 144),
+	},
+	{
+		Map{Orientation: Staggered, TileWidth: 64, TileHeight: 32, Width: 10, Height: 10, StaggerAxis: "x", StaggerIndex: "odd"},
+		image.Pt(3, 3), image.Rect(96, 112, 160, 144),
+	},
+	{
+		Map{Orientation: Hexagonal, TileWidth: 32, TileHeight: 32, HexSideLength: 16, Width: 10, Height: 10, StaggerAxis: "y", StaggerIndex: "odd"},
+		image.Pt(2, 3), image.Rect(80, 72, 112, 104),
+	},
+	{
+		Map{Orientation: Hexagonal, TileWidth: 32, TileHeight: 32, HexSideLength: 16, Width: 10, Height: 10, StaggerAxis: "y", StaggerIndex: "even"},
+		image.Pt(2, 3), image.Rect(64, 72, 96, 104),
+	},
+	{
+		Map{Orientation: Hexagonal, TileWidth: 32, TileHeight: 32, HexSideLength: 16, Width: 10, Height: 10, StaggerAxis: "x", StaggerIndex: "even"},
+		image.Pt(2, 3), image.Rect(48, 112, 80, 144),
+	},
+	{
+		// Odd sizes are rounded down to even ones, as in Tiled.
+		Map{Orientation: Hexagonal, TileWidth: 33, TileHeight: 29, HexSideLength: 16, Width: 10, Height: 10, StaggerAxis: "x", StaggerIndex: "odd"},
+		image.Pt(3, 3), image.Rect(72, 98, 104, 126),
+	},
+}
+
+func TestCellBounds(t *testing.T) {
+	for _, tst := range orientationTests {
+		m := tst.m
+		if got := m.CellBounds(tst.tile.X, tst.tile.Y); got != tst.cell {
+			t.Errorf("%s %s %s: cell of %v is %v, want %v", m.Orientation, m.StaggerAxis, m.StaggerIndex, tst.tile, got, tst.cell)
+		}
+	}
+}
+
+// TestTileAt checks that every point inside the outline of a cell is in its
+// tile, on maps of each orientation.
+func TestTileAt(t *testing.T) {
+	for _, tst := range orientationTests {
+		m := tst.m
+		for y := -3; y < 6; y++ {
+			for x := -3; x < 6; x++ {
+				want := image.Pt(x, y)
+				outline := m.CellOutline(x, y)
+				var c Vec2
+				for _, p := range outline {
+					c.X += p.X / float64(len(outline))
+					c.Y += p.Y / float64(len(outline))
+				}
+
+				// The center, and points close to each corner.
+				points := []Vec2{c}
+				for _, p := range outline {
+					points = append(points, Vec2{X: c.X + 0.9*(p.X-c.X), Y: c.Y + 0.9*(p.Y-c.Y)})
+				}
+				for _, p := range points {
+					if got := m.TileAt(p); got != want {
+						t.Errorf("%s %s %s: tile at %v is %v, want %v", m.Orientation, m.StaggerAxis, m.StaggerIndex, p, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestProject(t *testing.T) {
+	m := Map{Orientation: Isometric, TileWidth: 64, TileHeight: 32, Width: 10, Height: 10}
+
+	// Objects are positioned in units of TileHeight along the axes of the
+	// map, so the center of a tile is half a tile height along each axis.
+	for _, tile := range []image.Point{{0, 0}, {2, 3}, {9, 0}, {0, 9}} {
+		p := Vec2{X: (float64(tile.X) + 0.5) * 32, Y: (float64(tile.Y) + 0.5) * 32}
+		r := m.CellBounds(tile.X, tile.Y)
+		want := Vec2{X: float64(r.Min.X+r.Max.X) / 2, Y: float64(r.Min.Y+r.Max.Y) / 2}
+		if got := m.Project(p); got != want {
+			t.Errorf("%v projected to %v, want the center of %v at %v", p, got, tile, want)
+		}
+		if got := m.TileAt(m.Project(p)); got != tile {
+			t.Errorf("%v projected into tile %v, want %v", p, got, tile)
+		}
+	}
+
+	m.Orientation = Orthogonal
+	if p := (Vec2{X: 12, Y: 34}); m.Project(p) != p {
+		t.Errorf("orthogonal %v projected to %v", p, m.Project(p))
+	}
+}
+
+func TestSortTiles(t *testing.T) {
+	tests := []struct {
+		m    Map
+		want []image.Point
+	}{
+		{Map{Orientation: Orthogonal, RenderOrder: "right-down"}, []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}}},
+		{Map{Orientation: Orthogonal, RenderOrder: "right-up"}, []image.Point{{0, 1}, {1, 1}, {0, 0}, {1, 0}}},
+		{Map{Orientation: Orthogonal, RenderOrder: "left-down"}, []image.Point{{1, 0}, {0, 0}, {1, 1}, {0, 1}}},
+		{Map{Orientation: Orthogonal, RenderOrder: "left-up"}, []image.Point{{1, 1}, {0, 1}, {1, 0}, {0, 0}}},
+		// Diagonal rows from the top.
+		{Map{Orientation: Isometric}, []image.Point{{0, 0}, {0, 1}, {1, 0}, {1, 1}}},
+		// Shifted columns are lower, and drawn after the others of a row.
+		{Map{Orientation: Staggered, StaggerAxis: "x", StaggerIndex: "odd"}, []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}}},
+		{Map{Orientation: Hexagonal, StaggerAxis: "x", StaggerIndex: "even"}, []image.Point{{1, 0}, {0, 0}, {1, 1}, {0, 1}}},
+		{Map{Orientation: Hexagonal, StaggerAxis: "y", StaggerIndex: "even"}, []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}}},
+	}
+	for _, tst := range tests {
+		tiles := []image.Point{{1, 1}, {0, 1}, {1, 0}, {0, 0}}
+		tst.m.SortTiles(tiles)
+		if !reflect.DeepEqual(tiles, tst.want) {
+			t.Errorf("%s %s %s %s: got %v, want %v", tst.m.Orientation, tst.m.RenderOrder, tst.m.StaggerAxis, tst.m.StaggerIndex, tiles, tst.want)
+		}
+	}
+}
+
+func TestGIDFlags(t *testing.T) {
+	gid := 1234 | FlipHorizontal | FlipDiagonal | RotateHex120
+	if gid.ID() != 1234 {
+		t.Errorf("ID of %#x is %d, want 1234", uint32(gid), gid.ID())
+	}
+	if want := FlipHorizontal | FlipDiagonal | RotateHex120; gid.Flip() != want {
+		t.Errorf("flags of %#x are %#x, want %#x", uint32(gid), uint32(gid.Flip()), uint32(want))
+	}
+}
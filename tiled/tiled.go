@@ -0,0 +1,414 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tiled reads maps made with the Tiled map editor, that is TMX map
+// files and the TSX tileset files they reference.
+//
+// Unlike the engine's tmx package it only describes the map and does not build
+// any gfx objects, so that the examples can draw (and games can use) the map
+// however they need to.
+package tiled
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Map is a TMX map.
+type Map struct {
+	Version     string
+	Orientation string // One of Orthogonal, Isometric, Staggered or Hexagonal.
+	RenderOrder string // E.g. "right-down".
+
+	// StaggerAxis ("x" or "y") and StaggerIndex ("odd" or "even") are which
+	// columns or rows are shifted on staggered and hexagonal maps.
+	StaggerAxis, StaggerIndex string
+
+	// HexSideLength is the length in pixels of the sides of the hexagons of
+	// hexagonal maps that are parallel to the stagger axis.
+	HexSideLength int
+
+	// Width and Height are the size of the map in tiles. Infinite maps have
+	// no fixed size, their layers may extend beyond it in any direction.
+	Width, Height int
+	Infinite      bool
+
+	// TileWidth and TileHeight are the size of a tile in pixels.
+	TileWidth, TileHeight int
+
+	// BackgroundColor is the background color, transparent if not set.
+	BackgroundColor color.NRGBA
+
+	// ParallaxOrigin is the position in pixels on the map at which layers
+	// line up regardless of their parallax factors.
+	ParallaxOrigin Vec2
+
+	Properties Properties
+
+	// Tilesets are the tilesets of the map, ordered by their first GID.
+	Tilesets []*Tileset
+
+	// Layers are the top-level layers of the map, from the bottom one up.
+	Layers []Layer
+
+	// TileLayers and ObjectGroups are every tile and object layer of the map,
+	// including those in groups, from the bottom one up.
+	TileLayers   []*TileLayer
+	ObjectGroups []*ObjectGroup
+}
+
+// Tileset returns the tileset that the global tile ID belongs to, and the
+// local ID of the tile within it. If no tileset holds the tile, nil is
+// returned.
+func (m *Map) Tileset(gid GID) (ts *Tileset, id int) {
+	g := gid.ID()
+	if g == 0 {
+		return nil, 0
+	}
+	for i := len(m.Tilesets) - 1; i >= 0; i-- {
+		ts = m.Tilesets[i]
+		if g >= ts.FirstGID {
+			return ts, int(g - ts.FirstGID)
+		}
+	}
+	return nil, 0
+}
+
+// ObjectsOfType returns the objects of every object group with the given type
+// (or class, as newer versions of Tiled call it), in map order.
+func (m *Map) ObjectsOfType(typ string) []*Object {
+	var objects []*Object
+	for _, g := range m.ObjectGroups {
+		for _, o := range g.Objects {
+			if o.Type == typ {
+				objects = append(objects, o)
+			}
+		}
+	}
+	return objects
+}
+
+// GID is a global tile ID: the ID of a tile unique across all tilesets of the
+// map, with the flip flags in its highest bits.
+type GID uint32
+
+// The flip flags of a GID. On hexagonal maps, FlipDiagonal rotates the tile
+// clockwise by 60 degrees instead of flipping it, and RotateHex120 by 120
+// degrees.
+const (
+	FlipHorizontal GID = 1 << 31
+	FlipVertical   GID = 1 << 30
+	FlipDiagonal   GID = 1 << 29
+	RotateHex120   GID = 1 << 28
+
+	flipMask = FlipHorizontal | FlipVertical | FlipDiagonal | RotateHex120
+)
+
+// ID returns the global tile ID without the flip flags, zero meaning no tile.
+func (g GID) ID() uint32 { return uint32(g &^ flipMask) }
+
+// Flip returns the flip flags of the GID.
+func (g GID) Flip() GID { return g & flipMask }
+
+// Tileset is a set of tiles, cut from a single image or made up of one image
+// per tile.
+type Tileset struct {
+	// FirstGID is the global tile ID of the first tile.
+	FirstGID uint32
+
+	// Source is the path of the TSX file the tileset was loaded from, relative
+	// to the map, or empty if it is embedded into the map.
+	Source string
+
+	Name string
+
+	// TileWidth and TileHeight are the maximum size of a tile in pixels.
+	TileWidth, TileHeight int
+
+	// Spacing is the space between tiles in the image, and Margin the space
+	// around them, in pixels.
+	Spacing, Margin int
+
+	// TileCount and Columns are the number of tiles and tile columns. For
+	// older files that do not specify them, they are calculated from the
+	// image size.
+	TileCount, Columns int
+
+	// TileOffset is the offset in pixels at which tiles are drawn.
+	TileOffset image.Point
+
+	// Image is the image holding the tiles, nil for image collection
+	// tilesets.
+	Image *Image
+
+	Properties Properties
+
+	// Tiles holds the tiles that have any data beyond their position in the
+	// image (e.g. properties or their own image), by local ID.
+	Tiles map[int]*Tile
+}
+
+// TileRect returns the rectangle of the image holding the tile with the local
+// ID, that is Tiles[id].Image for image collections or an area of Image.
+func (ts *Tileset) TileRect(id int) image.Rectangle {
+	if t, ok := ts.Tiles[id]; ok && t.Image != nil {
+		return image.Rect(0, 0, t.Image.Width, t.Image.Height)
+	}
+	if ts.Columns == 0 {
+		return image.Rectangle{}
+	}
+	x := ts.Margin + (id%ts.Columns)*(ts.TileWidth+ts.Spacing)
+	y := ts.Margin + (id/ts.Columns)*(ts.TileHeight+ts.Spacing)
+	return image.Rect(x, y, x+ts.TileWidth, y+ts.TileHeight)
+}
+
+// Tile is a tile of a tileset.
+type Tile struct {
+	// ID is the local ID of the tile within its tileset.
+	ID int
+
+	// Type is the type (or class) of the tile.
+	Type string
+
+	Properties Properties
+
+	// Image is the image of the tile in image collection tilesets, or nil.
+	Image *Image
+
+	// Animation are the frames of the tile's animation, nil if it is not
+	// animated.
+	Animation []Frame
+}
+
+// Frame is a frame of a tile animation.
+type Frame struct {
+	// TileID is the local ID of the tile shown, within the same tileset.
+	TileID int
+
+	// Duration is how long the frame is shown.
+	Duration time.Duration
+}
+
+// Frame returns the local ID of the tile shown once its looping animation has
+// played for the elapsed time: its own ID if it is not animated.
+func (t *Tile) Frame(elapsed time.Duration) int {
+	var total time.Duration
+	for _, f := range t.Animation {
+		total += f.Duration
+	}
+	if total <= 0 {
+		return t.ID
+	}
+	elapsed %= total
+	if elapsed < 0 {
+		elapsed += total
+	}
+	for _, f := range t.Animation {
+		if elapsed < f.Duration {
+			return f.TileID
+		}
+		elapsed -= f.Duration
+	}
+	return t.ID
+}
+
+// Image is an image referenced by the map.
+type Image struct {
+	// Source is the path of the image as written in the file, relative to the
+	// file referencing it.
+	Source string
+
+	// Path is the path of the image within the file system the map was opened
+	// from, or Source if the map was decoded from a reader.
+	Path string
+
+	// Width and Height are the size of the image in pixels, if known.
+	Width, Height int
+
+	// Trans is the color that is treated as transparent, if Trans.A is
+	// non-zero.
+	Trans color.NRGBA
+}
+
+// Properties are the custom properties of a map, layer, tileset, tile or
+// object. Values are stored as written in the file; the methods parse them for
+// the property types Tiled supports.
+type Properties map[string]string
+
+// String returns the named property, or def if it is not set.
+func (p Properties) String(name, def string) string {
+	if v, ok := p[name]; ok {
+		return v
+	}
+	return def
+}
+
+// Int returns the named integer property, or def if it is not set or not an
+// integer.
+func (p Properties) Int(name string, def int) int {
+	if v, err := strconv.Atoi(p[name]); err == nil {
+		return v
+	}
+	return def
+}
+
+// Float returns the named float property, or def if it is not set or not a
+// number.
+func (p Properties) Float(name string, def float64) float64 {
+	if v, err := strconv.ParseFloat(p[name], 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// Bool returns the named bool property, or def if it is not set or not a
+// bool.
+func (p Properties) Bool(name string, def bool) bool {
+	if v, err := strconv.ParseBool(p[name]); err == nil {
+		return v
+	}
+	return def
+}
+
+// Color returns the named color property, or def if it is not set or not a
+// color.
+func (p Properties) Color(name string, def color.NRGBA) color.NRGBA {
+	if v, ok := p[name]; ok && len(v) > 0 {
+		if c, err := parseColor(v); err == nil {
+			return c
+		}
+	}
+	return def
+}
+
+// Open reads the map file with the given name from the file system, along with
+// the external tilesets it references. Paths of images are resolved relative
+// to the file system, see Image.Path.
+func Open(fsys fs.FS, name string) (*Map, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var xm xmlMap
+	if err := xml.NewDecoder(f).Decode(&xm); err != nil {
+		return nil, fmt.Errorf("tiled: %s: %v", name, err)
+	}
+	m, err := xm.convert(path.Dir(name))
+	if err != nil {
+		return nil, fmt.Errorf("tiled: %s: %v", name, err)
+	}
+
+	// Load the external tilesets.
+	for _, ts := range m.Tilesets {
+		if len(ts.Source) == 0 {
+			continue
+		}
+		p := path.Join(path.Dir(name), ts.Source)
+		if err := openTileset(fsys, p, ts); err != nil {
+			return nil, err
+		}
+	}
+	m.inherit()
+	return m, nil
+}
+
+// Decode reads a map from r. External tilesets cannot be loaded without a file
+// system and are left with only their first GID and source set.
+func Decode(r io.Reader) (*Map, error) {
+	var xm xmlMap
+	if err := xml.NewDecoder(r).Decode(&xm); err != nil {
+		return nil, fmt.Errorf("tiled: %v", err)
+	}
+	m, err := xm.convert("")
+	if err != nil {
+		return nil, fmt.Errorf("tiled: %v", err)
+	}
+	m.inherit()
+	return m, nil
+}
+
+// openTileset reads the TSX file at name into ts, keeping its first GID and
+// source.
+func openTileset(fsys fs.FS, name string, ts *Tileset) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("tiled: tileset: %v", err)
+	}
+	defer f.Close()
+
+	var xt xmlTileset
+	if err := xml.NewDecoder(f).Decode(&xt); err != nil {
+		return fmt.Errorf("tiled: %s: %v", name, err)
+	}
+	loaded, err := xt.convert(path.Dir(name))
+	if err != nil {
+		return fmt.Errorf("tiled: %s: %v", name, err)
+	}
+	loaded.FirstGID, loaded.Source = ts.FirstGID, ts.Source
+	*ts = *loaded
+	return nil
+}
+
+// inherit applies the size, type and properties of the tiles of tile objects
+// to the objects, for those they do not override.
+func (m *Map) inherit() {
+	for _, g := range m.ObjectGroups {
+		for _, o := range g.Objects {
+			if o.GID == 0 {
+				continue
+			}
+			ts, id := m.Tileset(o.GID)
+			if ts == nil {
+				continue
+			}
+			if o.Width == 0 && o.Height == 0 {
+				r := ts.TileRect(id)
+				o.Width, o.Height = float64(r.Dx()), float64(r.Dy())
+			}
+			t, ok := ts.Tiles[id]
+			if !ok {
+				continue
+			}
+			if len(o.Type) == 0 {
+				o.Type = t.Type
+			}
+			for k, v := range t.Properties {
+				if _, ok := o.Properties[k]; !ok {
+					if o.Properties == nil {
+						o.Properties = make(Properties)
+					}
+					o.Properties[k] = v
+				}
+			}
+		}
+	}
+}
+
+// parseColor parses a color written as "#rrggbb" or "#aarrggbb" (the leading
+// hash being optional).
+func parseColor(s string) (color.NRGBA, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) != 6 && len(h) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	c := color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+	if len(h) == 8 {
+		c.A = uint8(v >> 24)
+	}
+	return c, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"testing"
+	"time"
+)
+
+// animated is a tile animated by three frames, 350ms long in total.
+var animated = &Tile{ID: 7, Animation: []Frame{
+	{TileID: 1, Duration: 100 * time.Millisecond},
+	{TileID: 2, Duration: 200 * time.Millisecond},
+	{TileID: 3, Duration: 50 * time.Millisecond},
+}}
+
+var frameTests = []struct {
+	t       *Tile
+	elapsed time.Duration
+	want    int
+}{
+	{animated, 0, 1},
+	{animated, 99 * time.Millisecond, 1},
+
+	// Each frame starts once the one before has been shown for its duration.
+	{animated, 100 * time.Millisecond, 2},
+	{animated, 299 * time.Millisecond, 2},
+	{animated, 300 * time.Millisecond, 3},
+	{animated, 349 * time.Millisecond, 3},
+
+	// The animation loops.
+	{animated, 350 * time.Millisecond, 1},
+	{animated, 450 * time.Millisecond, 2},
+	{animated, 10*350*time.Millisecond + 320*time.Millisecond, 3},
+
+	// Before the start, the animation plays backwards from its end.
+	{animated, -1 * time.Millisecond, 3},
+	{animated, -50 * time.Millisecond, 3},
+	{animated, -51 * time.Millisecond, 2},
+	{animated, -250 * time.Millisecond, 2},
+	{animated, -251 * time.Millisecond, 1},
+	{animated, -350 * time.Millisecond, 1},
+	{animated, -351 * time.Millisecond, 3},
+
+	// Tiles that are not animated, or whose animation has no length, show
+	// themselves.
+	{&Tile{ID: 7}, time.Second, 7},
+	{&Tile{ID: 7, Animation: []Frame{{TileID: 1}, {TileID: 2}}}, 0, 7},
+	{&Tile{ID: 7, Animation: []Frame{{TileID: 1}, {TileID: 2}}}, -time.Second, 7},
+
+	// Frames without a length are skipped.
+	{&Tile{ID: 7, Animation: []Frame{{TileID: 1}, {TileID: 2, Duration: time.Second}}}, 0, 2},
+}
+
+func TestFrame(t *testing.T) {
+	for _, tst := range frameTests {
+		if got := tst.t.Frame(tst.elapsed); got != tst.want {
+			t.Errorf("tile %d with %d frames at %v: got tile %d, want %d", tst.t.ID, len(tst.t.Animation), tst.elapsed, got, tst.want)
+		}
+	}
+}
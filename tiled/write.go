@@ -0,0 +1,207 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// writeChunkSize is the width and height in tiles of the chunks that the
+// tiles of infinite maps are written as, the same as Tiled uses.
+const writeChunkSize = 16
+
+// Rewrite returns the TMX file src, which the map was read from, with the
+// tiles of each tile layer replaced by those the layer holds now. Everything
+// else in src is kept as it is, and every layer is written with the encoding
+// and compression it was read with, such that the file can still be edited
+// with Tiled.
+//
+// The layers of the map must not have been added, removed or resized since
+// it was read.
+func (m *Map) Rewrite(src []byte) ([]byte, error) {
+	var (
+		out     bytes.Buffer
+		copied  int64 // How much of src has been written to out.
+		layer   int   // The index of the next tile layer.
+		parents []string
+		start   int64 = -1 // Where the content of the data element starts.
+	)
+	d := xml.NewDecoder(bytes.NewReader(src))
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tiled: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "data" && len(parents) > 0 && parents[len(parents)-1] == "layer" {
+				start = d.InputOffset()
+				if bytes.HasSuffix(src[:start], []byte("/>")) {
+					return nil, errors.New("tiled: empty data element")
+				}
+			}
+			parents = append(parents, t.Name.Local)
+
+		case xml.EndElement:
+			parents = parents[:len(parents)-1]
+			if t.Name.Local != "data" || start < 0 {
+				break
+			}
+			if layer >= len(m.TileLayers) {
+				return nil, errors.New("tiled: the map has fewer tile layers than the file")
+			}
+			l := m.TileLayers[layer]
+			content, err := l.encode(string(src[start:offset]), m.Infinite)
+			if err != nil {
+				return nil, fmt.Errorf("tiled: layer %q: %v", l.Name, err)
+			}
+			out.Write(src[copied:start])
+			out.WriteString(content)
+			copied, start = offset, -1
+			layer++
+		}
+	}
+	if layer != len(m.TileLayers) {
+		return nil, errors.New("tiled: the map has more tile layers than the file")
+	}
+	out.Write(src[copied:])
+	return out.Bytes(), nil
+}
+
+// encode returns the content of the data element of the layer, formatted
+// with the same indentation as the content it replaces, old.
+func (l *TileLayer) encode(old string, infinite bool) (string, error) {
+	trimmed := strings.TrimLeft(old, " \t\r\n")
+	lead := old[:len(old)-len(trimmed)]
+	trail := old[len(strings.TrimRight(old, " \t\r\n")):]
+	indent := lead[strings.LastIndex(lead, "\n")+1:]
+
+	if !infinite {
+		return encodeTiles(l.Encoding, l.Compression, l.Tiles, l.Width, indent, trail)
+	}
+
+	// Write the chunks that hold any tiles, aligned to the grid of chunks.
+	var b strings.Builder
+	bounds := l.Bounds()
+	align := func(v int) int {
+		if v < 0 {
+			v -= writeChunkSize - 1
+		}
+		return v / writeChunkSize * writeChunkSize
+	}
+	for y := align(bounds.Min.Y); y < bounds.Max.Y; y += writeChunkSize {
+		for x := align(bounds.Min.X); x < bounds.Max.X; x += writeChunkSize {
+			tiles := make([]GID, 0, writeChunkSize*writeChunkSize)
+			empty := true
+			for ty := y; ty < y+writeChunkSize; ty++ {
+				for tx := x; tx < x+writeChunkSize; tx++ {
+					gid := l.At(tx, ty)
+					tiles = append(tiles, gid)
+					empty = empty && gid == 0
+				}
+			}
+			if empty {
+				continue
+			}
+			text, err := encodeTiles(l.Encoding, l.Compression, tiles, writeChunkSize, indent+" ", "\n"+indent)
+			if err != nil {
+				return "", err
+			}
+			r := image.Rect(x, y, x+writeChunkSize, y+writeChunkSize)
+			fmt.Fprintf(&b, "\n%s<chunk x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\">%s</chunk>",
+				indent, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), text)
+		}
+	}
+	b.WriteString(trail)
+	return b.String(), nil
+}
+
+// encodeTiles returns the tiles, width tiles per row, encoded as the content
+// of a data or chunk element. Lines are indented with indent, except for CSV
+// rows which Tiled does not indent, and end is written last.
+func encodeTiles(encoding, compression string, tiles []GID, width int, indent, end string) (string, error) {
+	var b strings.Builder
+	switch encoding {
+	case "":
+		for _, gid := range tiles {
+			if gid == 0 {
+				fmt.Fprintf(&b, "\n%s<tile/>", indent)
+			} else {
+				fmt.Fprintf(&b, "\n%s<tile gid=\"%d\"/>", indent, gid)
+			}
+		}
+
+	case "csv":
+		b.WriteString("\n")
+		for i, gid := range tiles {
+			b.WriteString(strconv.FormatUint(uint64(gid), 10))
+			switch {
+			case i == len(tiles)-1:
+			case (i+1)%width == 0:
+				b.WriteString(",\n")
+			default:
+				b.WriteString(",")
+			}
+		}
+
+	case "base64":
+		data := make([]byte, 4*len(tiles))
+		for i, gid := range tiles {
+			binary.LittleEndian.PutUint32(data[i*4:], uint32(gid))
+		}
+		data, err := compress(compression, data)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString("\n" + indent + base64.StdEncoding.EncodeToString(data))
+
+	default:
+		return "", fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	b.WriteString(end)
+	return b.String(), nil
+}
+
+// compress compresses data with the given method, an empty string meaning
+// none. As with decompress, zstd is unsupported.
+func compress(compression string, data []byte) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+	switch compression {
+	case "":
+		return data, nil
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,570 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiled
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The xml* types mirror the elements of TMX and TSX files as they are
+// written, and are converted into the exported types once decoded.
+
+type xmlProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"` // Multi-line string values.
+}
+
+type xmlProperties struct {
+	Properties []xmlProperty `xml:"property"`
+}
+
+// convert returns the properties, nil if there are none.
+func (xp *xmlProperties) convert() Properties {
+	if xp == nil || len(xp.Properties) == 0 {
+		return nil
+	}
+	p := make(Properties, len(xp.Properties))
+	for _, prop := range xp.Properties {
+		v := prop.Value
+		if len(v) == 0 {
+			v = prop.Text
+		}
+		p[prop.Name] = v
+	}
+	return p
+}
+
+type xmlImage struct {
+	Source string `xml:"source,attr"`
+	Trans  string `xml:"trans,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+// convert returns the image, with its path resolved relative to dir.
+func (xi *xmlImage) convert(dir string) (*Image, error) {
+	if xi == nil {
+		return nil, nil
+	}
+	img := &Image{
+		Source: xi.Source,
+		Path:   path.Join(dir, xi.Source),
+		Width:  xi.Width,
+		Height: xi.Height,
+	}
+	if len(xi.Trans) > 0 {
+		c, err := parseColor(xi.Trans)
+		if err != nil {
+			return nil, err
+		}
+		img.Trans = c
+	}
+	return img, nil
+}
+
+type xmlFrame struct {
+	TileID   int `xml:"tileid,attr"`
+	Duration int `xml:"duration,attr"` // In milliseconds.
+}
+
+type xmlTile struct {
+	ID         int            `xml:"id,attr"`
+	Type       string         `xml:"type,attr"`
+	Class      string         `xml:"class,attr"`
+	Properties *xmlProperties `xml:"properties"`
+	Image      *xmlImage      `xml:"image"`
+	Animation  []xmlFrame     `xml:"animation>frame"`
+}
+
+type xmlTileset struct {
+	FirstGID   uint32 `xml:"firstgid,attr"`
+	Source     string `xml:"source,attr"`
+	Name       string `xml:"name,attr"`
+	TileWidth  int    `xml:"tilewidth,attr"`
+	TileHeight int    `xml:"tileheight,attr"`
+	Spacing    int    `xml:"spacing,attr"`
+	Margin     int    `xml:"margin,attr"`
+	TileCount  int    `xml:"tilecount,attr"`
+	Columns    int    `xml:"columns,attr"`
+	TileOffset *struct {
+		X int `xml:"x,attr"`
+		Y int `xml:"y,attr"`
+	} `xml:"tileoffset"`
+	Image      *xmlImage      `xml:"image"`
+	Properties *xmlProperties `xml:"properties"`
+	Tiles      []xmlTile      `xml:"tile"`
+}
+
+// convert returns the tileset, with image paths resolved relative to dir.
+func (xt *xmlTileset) convert(dir string) (*Tileset, error) {
+	ts := &Tileset{
+		FirstGID:   xt.FirstGID,
+		Source:     xt.Source,
+		Name:       xt.Name,
+		TileWidth:  xt.TileWidth,
+		TileHeight: xt.TileHeight,
+		Spacing:    xt.Spacing,
+		Margin:     xt.Margin,
+		TileCount:  xt.TileCount,
+		Columns:    xt.Columns,
+		Properties: xt.Properties.convert(),
+		Tiles:      make(map[int]*Tile, len(xt.Tiles)),
+	}
+	if xt.TileOffset != nil {
+		ts.TileOffset = image.Pt(xt.TileOffset.X, xt.TileOffset.Y)
+	}
+	var err error
+	if ts.Image, err = xt.Image.convert(dir); err != nil {
+		return nil, err
+	}
+	for _, xt := range xt.Tiles {
+		t := &Tile{
+			ID:         xt.ID,
+			Type:       xt.Type,
+			Properties: xt.Properties.convert(),
+		}
+		if len(xt.Class) > 0 {
+			t.Type = xt.Class
+		}
+		if t.Image, err = xt.Image.convert(dir); err != nil {
+			return nil, err
+		}
+		for _, f := range xt.Animation {
+			t.Animation = append(t.Animation, Frame{
+				TileID:   f.TileID,
+				Duration: time.Duration(f.Duration) * time.Millisecond,
+			})
+		}
+		ts.Tiles[t.ID] = t
+	}
+
+	// Older versions of Tiled do not write the tile count and columns.
+	if img := ts.Image; img != nil && ts.TileWidth > 0 && ts.TileHeight > 0 {
+		if ts.Columns == 0 {
+			ts.Columns = (img.Width - 2*ts.Margin + ts.Spacing) / (ts.TileWidth + ts.Spacing)
+		}
+		if ts.TileCount == 0 {
+			rows := (img.Height - 2*ts.Margin + ts.Spacing) / (ts.TileHeight + ts.Spacing)
+			ts.TileCount = ts.Columns * rows
+		}
+	}
+	return ts, nil
+}
+
+type xmlPoints struct {
+	Points string `xml:"points,attr"`
+}
+
+type xmlObject struct {
+	ID         int            `xml:"id,attr"`
+	Name       string         `xml:"name,attr"`
+	Type       string         `xml:"type,attr"`
+	Class      string         `xml:"class,attr"`
+	X          float64        `xml:"x,attr"`
+	Y          float64        `xml:"y,attr"`
+	Width      float64        `xml:"width,attr"`
+	Height     float64        `xml:"height,attr"`
+	Rotation   float64        `xml:"rotation,attr"`
+	GID        GID            `xml:"gid,attr"`
+	Visible    string         `xml:"visible,attr"`
+	Properties *xmlProperties `xml:"properties"`
+	Ellipse    *struct{}      `xml:"ellipse"`
+	Point      *struct{}      `xml:"point"`
+	Polygon    *xmlPoints     `xml:"polygon"`
+	Polyline   *xmlPoints     `xml:"polyline"`
+	Text       *struct{}      `xml:"text"`
+}
+
+// convert returns the object.
+func (xo *xmlObject) convert() (*Object, error) {
+	o := &Object{
+		ID:         xo.ID,
+		Name:       xo.Name,
+		Type:       xo.Type,
+		X:          xo.X,
+		Y:          xo.Y,
+		Width:      xo.Width,
+		Height:     xo.Height,
+		Rotation:   xo.Rotation,
+		GID:        xo.GID,
+		Visible:    xo.Visible != "0",
+		Properties: xo.Properties.convert(),
+	}
+	if len(xo.Class) > 0 {
+		o.Type = xo.Class
+	}
+
+	var err error
+	switch {
+	case xo.GID != 0:
+		o.Shape = TileShape
+	case xo.Ellipse != nil:
+		o.Shape = Ellipse
+	case xo.Point != nil:
+		o.Shape = Point
+	case xo.Polygon != nil:
+		o.Shape = Polygon
+		o.Points, err = parsePoints(xo.Polygon.Points)
+	case xo.Polyline != nil:
+		o.Shape = Polyline
+		o.Points, err = parsePoints(xo.Polyline.Points)
+	case xo.Text != nil:
+		o.Shape = Text
+	}
+	if err != nil {
+		return nil, fmt.Errorf("object %d: %v", xo.ID, err)
+	}
+	return o, nil
+}
+
+// parsePoints parses a list of points written as "x,y x,y ...".
+func parsePoints(s string) ([]Vec2, error) {
+	var points []Vec2
+	for _, f := range strings.Fields(s) {
+		xy := strings.Split(f, ",")
+		if len(xy) != 2 {
+			return nil, fmt.Errorf("invalid point %q", f)
+		}
+		x, err := strconv.ParseFloat(xy[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid point %q", f)
+		}
+		y, err := strconv.ParseFloat(xy[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid point %q", f)
+		}
+		points = append(points, Vec2{x, y})
+	}
+	return points, nil
+}
+
+type xmlObjectGroup struct {
+	xmlLayerInfo
+	Color     string      `xml:"color,attr"`
+	DrawOrder string      `xml:"draworder,attr"`
+	Objects   []xmlObject `xml:"object"`
+}
+
+// convert returns the object group, which is in the given group.
+func (xg *xmlObjectGroup) convert(parent *Group) (*ObjectGroup, error) {
+	info, err := xg.xmlLayerInfo.convert(parent)
+	if err != nil {
+		return nil, fmt.Errorf("object group %q: %v", xg.Name, err)
+	}
+	g := &ObjectGroup{
+		LayerInfo: info,
+		DrawOrder: xg.DrawOrder,
+	}
+	if len(g.DrawOrder) == 0 {
+		g.DrawOrder = "topdown"
+	}
+	if len(xg.Color) > 0 {
+		c, err := parseColor(xg.Color)
+		if err != nil {
+			return nil, fmt.Errorf("object group %q: %v", xg.Name, err)
+		}
+		g.Color = c
+	}
+	for i := range xg.Objects {
+		o, err := xg.Objects[i].convert()
+		if err != nil {
+			return nil, fmt.Errorf("object group %q: %v", xg.Name, err)
+		}
+		g.Objects = append(g.Objects, o)
+	}
+	return g, nil
+}
+
+type xmlLayerInfo struct {
+	Name       string         `xml:"name,attr"`
+	Opacity    *float64       `xml:"opacity,attr"`
+	Visible    string         `xml:"visible,attr"`
+	OffsetX    float64        `xml:"offsetx,attr"`
+	OffsetY    float64        `xml:"offsety,attr"`
+	TintColor  string         `xml:"tintcolor,attr"`
+	ParallaxX  *float64       `xml:"parallaxx,attr"`
+	ParallaxY  *float64       `xml:"parallaxy,attr"`
+	Properties *xmlProperties `xml:"properties"`
+}
+
+// convert returns the attributes of a layer in the given group.
+func (xi *xmlLayerInfo) convert(parent *Group) (LayerInfo, error) {
+	info := LayerInfo{
+		Name:       xi.Name,
+		Opacity:    1,
+		Visible:    xi.Visible != "0",
+		OffsetX:    xi.OffsetX,
+		OffsetY:    xi.OffsetY,
+		Tint:       color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		ParallaxX:  1,
+		ParallaxY:  1,
+		Properties: xi.Properties.convert(),
+		Parent:     parent,
+	}
+	if xi.Opacity != nil {
+		info.Opacity = *xi.Opacity
+	}
+	if xi.ParallaxX != nil {
+		info.ParallaxX = *xi.ParallaxX
+	}
+	if xi.ParallaxY != nil {
+		info.ParallaxY = *xi.ParallaxY
+	}
+	if len(xi.TintColor) > 0 {
+		c, err := parseColor(xi.TintColor)
+		if err != nil {
+			return info, err
+		}
+		info.Tint = c
+	}
+	return info, nil
+}
+
+type xmlImageLayer struct {
+	xmlLayerInfo
+	Image *xmlImage `xml:"image"`
+}
+
+type xmlGroup struct {
+	xmlLayerInfo
+	Layers []xmlAnyLayer `xml:",any"`
+}
+
+// xmlAnyLayer is any element among the layers of a map or group, such that
+// layers of every kind are kept in the order they appear in. Exactly one of
+// its fields is set for layers, none for other elements.
+type xmlAnyLayer struct {
+	tiles   *xmlLayer
+	objects *xmlObjectGroup
+	image   *xmlImageLayer
+	group   *xmlGroup
+}
+
+// UnmarshalXML decodes the element according to its name.
+func (xa *xmlAnyLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v interface{}
+	switch start.Name.Local {
+	case "layer":
+		xa.tiles = new(xmlLayer)
+		v = xa.tiles
+	case "objectgroup":
+		xa.objects = new(xmlObjectGroup)
+		v = xa.objects
+	case "imagelayer":
+		xa.image = new(xmlImageLayer)
+		v = xa.image
+	case "group":
+		xa.group = new(xmlGroup)
+		v = xa.group
+	default:
+		return d.Skip()
+	}
+	return d.DecodeElement(v, &start)
+}
+
+// convertLayers returns the layers in the given group (nil for the top-level
+// ones) of the map, adding the tile and object layers among them to those of
+// the map. Image paths are resolved relative to dir.
+func convertLayers(m *Map, xls []xmlAnyLayer, parent *Group, dir string) ([]Layer, error) {
+	var layers []Layer
+	for _, xl := range xls {
+		switch {
+		case xl.tiles != nil:
+			l, err := xl.tiles.convert(parent, m.Infinite)
+			if err != nil {
+				return nil, err
+			}
+			m.TileLayers = append(m.TileLayers, l)
+			layers = append(layers, l)
+
+		case xl.objects != nil:
+			g, err := xl.objects.convert(parent)
+			if err != nil {
+				return nil, err
+			}
+			m.ObjectGroups = append(m.ObjectGroups, g)
+			layers = append(layers, g)
+
+		case xl.image != nil:
+			info, err := xl.image.xmlLayerInfo.convert(parent)
+			if err != nil {
+				return nil, fmt.Errorf("image layer %q: %v", xl.image.Name, err)
+			}
+			img, err := xl.image.Image.convert(dir)
+			if err != nil {
+				return nil, fmt.Errorf("image layer %q: %v", xl.image.Name, err)
+			}
+			layers = append(layers, &ImageLayer{LayerInfo: info, Image: img})
+
+		case xl.group != nil:
+			info, err := xl.group.xmlLayerInfo.convert(parent)
+			if err != nil {
+				return nil, fmt.Errorf("group %q: %v", xl.group.Name, err)
+			}
+			g := &Group{LayerInfo: info}
+			if g.Layers, err = convertLayers(m, xl.group.Layers, g, dir); err != nil {
+				return nil, err
+			}
+			layers = append(layers, g)
+		}
+	}
+	return layers, nil
+}
+
+type xmlDataTile struct {
+	GID GID `xml:"gid,attr"`
+}
+
+type xmlChunk struct {
+	X      int           `xml:"x,attr"`
+	Y      int           `xml:"y,attr"`
+	Width  int           `xml:"width,attr"`
+	Height int           `xml:"height,attr"`
+	Text   string        `xml:",chardata"`
+	Tiles  []xmlDataTile `xml:"tile"`
+}
+
+type xmlData struct {
+	Encoding    string        `xml:"encoding,attr"`
+	Compression string        `xml:"compression,attr"`
+	Text        string        `xml:",chardata"`
+	Tiles       []xmlDataTile `xml:"tile"`
+	Chunks      []xmlChunk    `xml:"chunk"`
+}
+
+type xmlLayer struct {
+	xmlLayerInfo
+	Width  int     `xml:"width,attr"`
+	Height int     `xml:"height,attr"`
+	Data   xmlData `xml:"data"`
+}
+
+// convert returns the tile layer, which is in the given group. The tiles of
+// infinite maps are stored in chunks, which are copied into a single area
+// covering all of them.
+func (xl *xmlLayer) convert(parent *Group, infinite bool) (*TileLayer, error) {
+	info, err := xl.xmlLayerInfo.convert(parent)
+	if err != nil {
+		return nil, fmt.Errorf("layer %q: %v", xl.Name, err)
+	}
+	l := &TileLayer{
+		LayerInfo:   info,
+		Width:       xl.Width,
+		Height:      xl.Height,
+		Encoding:    xl.Data.Encoding,
+		Compression: xl.Data.Compression,
+	}
+	d := &xl.Data
+	if !infinite {
+		tiles, err := decodeTiles(d.Encoding, d.Compression, d.Text, d.Tiles, l.Width*l.Height)
+		if err != nil {
+			return nil, fmt.Errorf("layer %q: %v", xl.Name, err)
+		}
+		l.Tiles = tiles
+		return l, nil
+	}
+
+	var bounds image.Rectangle
+	for _, c := range d.Chunks {
+		bounds = bounds.Union(image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height))
+	}
+	l.X, l.Y = bounds.Min.X, bounds.Min.Y
+	l.Width, l.Height = bounds.Dx(), bounds.Dy()
+	l.Tiles = make([]GID, l.Width*l.Height)
+	for _, c := range d.Chunks {
+		tiles, err := decodeTiles(d.Encoding, d.Compression, c.Text, c.Tiles, c.Width*c.Height)
+		if err != nil {
+			return nil, fmt.Errorf("layer %q: chunk %d,%d: %v", xl.Name, c.X, c.Y, err)
+		}
+		for y := 0; y < c.Height; y++ {
+			i := (c.Y-l.Y+y)*l.Width + c.X - l.X
+			copy(l.Tiles[i:i+c.Width], tiles[y*c.Width:])
+		}
+	}
+	return l, nil
+}
+
+type xmlMap struct {
+	Version         string         `xml:"version,attr"`
+	Orientation     string         `xml:"orientation,attr"`
+	RenderOrder     string         `xml:"renderorder,attr"`
+	StaggerAxis     string         `xml:"staggeraxis,attr"`
+	StaggerIndex    string         `xml:"staggerindex,attr"`
+	HexSideLength   int            `xml:"hexsidelength,attr"`
+	Width           int            `xml:"width,attr"`
+	Height          int            `xml:"height,attr"`
+	TileWidth       int            `xml:"tilewidth,attr"`
+	TileHeight      int            `xml:"tileheight,attr"`
+	BackgroundColor string         `xml:"backgroundcolor,attr"`
+	ParallaxOriginX float64        `xml:"parallaxoriginx,attr"`
+	ParallaxOriginY float64        `xml:"parallaxoriginy,attr"`
+	Infinite        bool           `xml:"infinite,attr"`
+	Properties      *xmlProperties `xml:"properties"`
+	Tilesets        []xmlTileset   `xml:"tileset"`
+	Layers          []xmlAnyLayer  `xml:",any"`
+}
+
+// convert returns the map, with paths resolved relative to dir.
+func (xm *xmlMap) convert(dir string) (*Map, error) {
+	m := &Map{
+		Version:        xm.Version,
+		Orientation:    xm.Orientation,
+		RenderOrder:    xm.RenderOrder,
+		StaggerAxis:    xm.StaggerAxis,
+		StaggerIndex:   xm.StaggerIndex,
+		HexSideLength:  xm.HexSideLength,
+		Width:          xm.Width,
+		Height:         xm.Height,
+		TileWidth:      xm.TileWidth,
+		TileHeight:     xm.TileHeight,
+		Infinite:       xm.Infinite,
+		Properties:     xm.Properties.convert(),
+		ParallaxOrigin: Vec2{xm.ParallaxOriginX, xm.ParallaxOriginY},
+	}
+	if len(m.Orientation) == 0 {
+		m.Orientation = Orthogonal
+	}
+	if len(m.RenderOrder) == 0 {
+		m.RenderOrder = "right-down"
+	}
+	if len(m.StaggerAxis) == 0 {
+		m.StaggerAxis = "y"
+	}
+	if len(m.StaggerIndex) == 0 {
+		m.StaggerIndex = "odd"
+	}
+	if len(xm.BackgroundColor) > 0 {
+		c, err := parseColor(xm.BackgroundColor)
+		if err != nil {
+			return nil, err
+		}
+		m.BackgroundColor = c
+	}
+	for i := range xm.Tilesets {
+		ts, err := xm.Tilesets[i].convert(dir)
+		if err != nil {
+			return nil, fmt.Errorf("tileset %q: %v", xm.Tilesets[i].Name, err)
+		}
+		m.Tilesets = append(m.Tilesets, ts)
+	}
+	sort.SliceStable(m.Tilesets, func(i, j int) bool {
+		return m.Tilesets[i].FirstGID < m.Tilesets[j].FirstGID
+	})
+	layers, err := convertLayers(m, xm.Layers, nil, dir)
+	if err != nil {
+		return nil, err
+	}
+	m.Layers = layers
+	return m, nil
+}
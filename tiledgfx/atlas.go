@@ -0,0 +1,189 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiledgfx
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/tiled"
+)
+
+// atlasSize is the largest width and height of an atlas texture, which any
+// GPU the engine runs on supports.
+const atlasSize = 2048
+
+// atlasPadding is how many pixels the edges of each tile are extruded by in
+// an atlas, such that sampling just outside of a tile (as happens when the
+// map is zoomed by a fraction) gives the color of its edge rather than that of
+// the next tile.
+const atlasPadding = 2
+
+// atlasKey identifies a tile by the path of its image and its rectangle in
+// the image.
+type atlasKey struct {
+	path string
+	rect image.Rectangle
+}
+
+// atlasEntry is where a tile is in an atlas.
+type atlasEntry struct {
+	tex  *gfx.Texture
+	rect image.Rectangle
+}
+
+// Atlas holds the tiles of every tileset of a map packed into as few textures
+// as possible, such that runs of tiles from different tilesets can be drawn
+// at once.
+type Atlas struct {
+	tiles    map[atlasKey]atlasEntry
+	textures []*gfx.Texture
+	images   int // The number of images the tiles were packed from.
+}
+
+// String returns a description of the atlas.
+func (a *Atlas) String() string {
+	sizes := make([]string, len(a.textures))
+	for i, tex := range a.textures {
+		sizes[i] = fmt.Sprintf("%dx%d", tex.Bounds.Dx(), tex.Bounds.Dy())
+	}
+	return fmt.Sprintf("%d tiles of %d images packed into %d atlas textures (%s)",
+		len(a.tiles), a.images, len(a.textures), strings.Join(sizes, ", "))
+}
+
+// NewAtlas packs the tiles of every tileset of the map into atlas textures,
+// loading the tileset images with textures. Tiles too large for an
+// atlas are left out, and drawn from their own image.
+func NewAtlas(m *tiled.Map, textures *Textures) (*Atlas, error) {
+	// Collect every tile once, even if tilesets share an image.
+	var keys []atlasKey
+	seen := make(map[atlasKey]bool)
+	images := make(map[string]bool)
+	add := func(img *tiled.Image, r image.Rectangle) {
+		k := atlasKey{img.Path, r}
+		if r.Empty() || seen[k] || r.Dx()+2*atlasPadding > atlasSize || r.Dy()+2*atlasPadding > atlasSize {
+			return
+		}
+		seen[k] = true
+		images[img.Path] = true
+		keys = append(keys, k)
+	}
+	for _, ts := range m.Tilesets {
+		if ts.Image != nil {
+			for id := 0; id < ts.TileCount; id++ {
+				add(ts.Image, ts.TileRect(id))
+			}
+		}
+		for id, t := range ts.Tiles {
+			if t.Image != nil {
+				add(t.Image, ts.TileRect(id))
+			}
+		}
+	}
+
+	// Pack the tallest tiles first onto shelves, the rows of an atlas, which
+	// are as tall as the first tile placed on them.
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i].rect.Size(), keys[j].rect.Size()
+		return a.Y > b.Y || a.Y == b.Y && a.X > b.X
+	})
+	type placement struct {
+		page int
+		at   image.Point // The top-left corner of the padded tile.
+	}
+	var (
+		placed       = make([]placement, len(keys))
+		sizes        []image.Point // The area used of each page.
+		x, y, shelfH int
+		page         = -1
+	)
+	for i, k := range keys {
+		w, h := k.rect.Dx()+2*atlasPadding, k.rect.Dy()+2*atlasPadding
+		if page >= 0 && x+w > atlasSize {
+			x, y, shelfH = 0, y+shelfH, 0
+		}
+		if page < 0 || y+h > atlasSize {
+			page++
+			sizes = append(sizes, image.Point{})
+			x, y, shelfH = 0, 0, 0
+		}
+		placed[i] = placement{page, image.Pt(x, y)}
+		x += w
+		if h > shelfH {
+			shelfH = h
+		}
+		if x > sizes[page].X {
+			sizes[page].X = x
+		}
+		if y+shelfH > sizes[page].Y {
+			sizes[page].Y = y + shelfH
+		}
+	}
+
+	// Copy the tiles into the pages, each sized to a power of two.
+	pages := make([]*image.NRGBA, len(sizes))
+	a := &Atlas{tiles: make(map[atlasKey]atlasEntry, len(keys)), images: len(images)}
+	for i, size := range sizes {
+		pages[i] = image.NewNRGBA(image.Rect(0, 0, powerOfTwo(size.X), powerOfTwo(size.Y)))
+		a.textures = append(a.textures, newMapTexture(pages[i]))
+	}
+	for i, k := range keys {
+		tex, err := textures.open(k.path)
+		if err != nil {
+			return nil, err
+		}
+		p := placed[i]
+		extrude(pages[p.page], p.at, tex.Source, k.rect)
+		r := image.Rectangle{Min: p.at, Max: p.at.Add(k.rect.Size())}
+		a.tiles[k] = atlasEntry{a.textures[p.page], r.Add(image.Pt(atlasPadding, atlasPadding))}
+	}
+	return a, nil
+}
+
+// extrude copies the rectangle r of src to dst with its top-left corner at
+// atlasPadding pixels right and down from at, repeating the pixels at its
+// edges into the padding around it.
+func extrude(dst *image.NRGBA, at image.Point, src image.Image, r image.Rectangle) {
+	b := src.Bounds()
+	clamp := func(v, min, max int) int {
+		if v < min {
+			return min
+		}
+		if v >= max {
+			return max - 1
+		}
+		return v
+	}
+	for y := -atlasPadding; y < r.Dy()+atlasPadding; y++ {
+		sy := clamp(b.Min.Y+r.Min.Y+y, b.Min.Y+r.Min.Y, b.Min.Y+r.Max.Y)
+		for x := -atlasPadding; x < r.Dx()+atlasPadding; x++ {
+			sx := clamp(b.Min.X+r.Min.X+x, b.Min.X+r.Min.X, b.Min.X+r.Max.X)
+			dst.Set(at.X+atlasPadding+x, at.Y+atlasPadding+y, src.At(sx, sy))
+		}
+	}
+}
+
+// powerOfTwo returns the smallest power of two that is at least v.
+func powerOfTwo(v int) int {
+	p := 1
+	for p < v {
+		p *= 2
+	}
+	return p
+}
+
+// CountDraws returns how many objects the layers draw when all of the map is
+// in view.
+func CountDraws(layers []*Layer) int {
+	n := 0
+	for _, l := range layers {
+		n += len(l.all)
+	}
+	return n
+}
@@ -0,0 +1,188 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiledgfx
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/tiled"
+)
+
+// patternImage returns an image whose pixels each have a different color,
+// with its bounds at the given origin.
+func patternImage(origin image.Point, w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rectangle{Min: origin, Max: origin.Add(image.Pt(w, h))})
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(origin.X+x, origin.Y+y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x>>8<<4 | y>>8), A: 255})
+		}
+	}
+	return img
+}
+
+func TestExtrude(t *testing.T) {
+	// A 3x2 tile at 1,1 of an image whose bounds do not start at the origin.
+	src := patternImage(image.Pt(10, 20), 6, 4)
+	r := image.Rect(1, 1, 4, 3)
+	dst := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	at := image.Pt(5, 6)
+	extrude(dst, at, src, r)
+
+	for y := -atlasPadding; y < r.Dy()+atlasPadding; y++ {
+		for x := -atlasPadding; x < r.Dx()+atlasPadding; x++ {
+			// Pixels in the padding are those of the nearest edge.
+			sx := 10 + r.Min.X + clampInt(x, 0, r.Dx()-1)
+			sy := 20 + r.Min.Y + clampInt(y, 0, r.Dy()-1)
+			got := dst.At(at.X+atlasPadding+x, at.Y+atlasPadding+y)
+			if want := src.At(sx, sy); got != want {
+				t.Errorf("pixel %d,%d of the tile is %v, want %v from %d,%d", x, y, got, want, sx, sy)
+			}
+		}
+	}
+
+	// Nothing is written around the padding.
+	padded := image.Rectangle{Min: at, Max: at.Add(r.Size()).Add(image.Pt(2*atlasPadding, 2*atlasPadding))}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if !image.Pt(x, y).In(padded) && dst.At(x, y) != (color.NRGBA{}) {
+				t.Errorf("pixel %d,%d outside of the tile was written", x, y)
+			}
+		}
+	}
+}
+
+// clampInt returns v limited to the range min to max.
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func TestPowerOfTwo(t *testing.T) {
+	for _, tst := range []struct{ v, want int }{{0, 1}, {1, 1}, {2, 2}, {3, 4}, {36, 64}, {1024, 1024}, {1025, 2048}} {
+		if got := powerOfTwo(tst.v); got != tst.want {
+			t.Errorf("powerOfTwo(%d) = %d, want %d", tst.v, got, tst.want)
+		}
+	}
+}
+
+// checkAtlas checks that every tile of the atlas is within its page, copied
+// from its image, and that no two tiles overlap with their padding.
+func checkAtlas(t *testing.T, a *Atlas, textures *Textures) {
+	t.Helper()
+	for _, tex := range a.textures {
+		b := tex.Bounds
+		if b.Dx() > atlasSize || b.Dy() > atlasSize || powerOfTwo(b.Dx()) != b.Dx() || powerOfTwo(b.Dy()) != b.Dy() {
+			t.Errorf("atlas page of %dx%d pixels", b.Dx(), b.Dy())
+		}
+	}
+
+	pad := image.Pt(atlasPadding, atlasPadding)
+	type padded struct {
+		tex  *gfx.Texture
+		rect image.Rectangle
+	}
+	var placed []padded
+	for k, e := range a.tiles {
+		r := image.Rectangle{Min: e.rect.Min.Sub(pad), Max: e.rect.Max.Add(pad)}
+		if !r.In(e.tex.Bounds) {
+			t.Errorf("tile %v of %s is at %v, outside of its %v page", k.rect, k.path, r, e.tex.Bounds)
+			continue
+		}
+		for _, p := range placed {
+			if p.tex == e.tex && p.rect.Overlaps(r) {
+				t.Errorf("tile %v of %s at %v overlaps another at %v", k.rect, k.path, r, p.rect)
+			}
+		}
+		placed = append(placed, padded{e.tex, r})
+
+		src := textures.textures[k.path].Source
+		page := e.tex.Source
+		for _, p := range []image.Point{{0, 0}, {k.rect.Dx() - 1, 0}, {k.rect.Dx() / 2, k.rect.Dy() / 2}, {k.rect.Dx() - 1, k.rect.Dy() - 1}} {
+			if got, want := page.At(e.rect.Min.X+p.X, e.rect.Min.Y+p.Y), src.At(k.rect.Min.X+p.X, k.rect.Min.Y+p.Y); got != want {
+				t.Errorf("pixel %v of tile %v of %s is %v, want %v", p, k.rect, k.path, got, want)
+			}
+		}
+	}
+}
+
+// TestAtlasMap packs the tilesets of atlas.tmx, whose tiles fit one page.
+func TestAtlasMap(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"atlas.tmx", "tilesheet.tsx", "tilesheet_blue.tsx"} {
+		data, err := os.ReadFile("../azul3d_tmx/data/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys[name] = &fstest.MapFile{Data: data}
+	}
+	m, err := tiled.Open(fsys, "atlas.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The images are stored with Git LFS and may not be checked out, so
+	// they are replaced by others of their size.
+	textures := &Textures{FS: fsys, textures: map[string]*gfx.Texture{
+		"tilesheet.png":      newMapTexture(patternImage(image.Point{}, 288, 96)),
+		"tilesheet_blue.png": newMapTexture(patternImage(image.Point{}, 288, 96)),
+	}}
+	a, err := NewAtlas(m, textures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.tiles) != 54 || a.images != 2 || len(a.textures) != 1 {
+		t.Errorf("got %v, want 54 tiles of 2 images packed into 1 atlas texture", a)
+	}
+	checkAtlas(t, a, textures)
+
+	// Tiles are drawn from the atlas once it is set.
+	textures.Atlas = a
+	tex, r, err := textures.tile(m.Tilesets[1], 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := a.tiles[atlasKey{"tilesheet_blue.png", image.Rect(128, 0, 160, 32)}]; tex != want.tex || r != want.rect {
+		t.Errorf("tile 4 of tilesheet_blue is at %v of %p, want %v of %p", r, tex, want.rect, want.tex)
+	}
+}
+
+// TestAtlasPages packs more tiles than fit one page.
+func TestAtlasPages(t *testing.T) {
+	// 30 tiles of 500x500 pixels, of which 16 fit a page with their padding.
+	const size, columns, count = 500, 6, 30
+	img := patternImage(image.Point{}, columns*size, count/columns*size)
+	m := &tiled.Map{Tilesets: []*tiled.Tileset{{
+		FirstGID:   1,
+		Name:       "big",
+		TileWidth:  size,
+		TileHeight: size,
+		TileCount:  count,
+		Columns:    columns,
+		Image:      &tiled.Image{Source: "big.png", Path: "big.png", Width: img.Rect.Dx(), Height: img.Rect.Dy()},
+	}}}
+	textures := &Textures{textures: map[string]*gfx.Texture{"big.png": newMapTexture(img)}}
+	a, err := NewAtlas(m, textures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.tiles) != count || len(a.textures) != 2 {
+		t.Fatalf("got %v, want %d tiles packed into 2 atlas textures", a, count)
+	}
+	if b := a.textures[0].Bounds; b != image.Rect(0, 0, atlasSize, atlasSize) {
+		t.Errorf("the first page is %v, want a full page", b)
+	}
+	checkAtlas(t, a, textures)
+}
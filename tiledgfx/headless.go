@@ -0,0 +1,28 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiledgfx
+
+import (
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/headless"
+)
+
+// HeadlessShaders are the fragment shaders that a headless.Device draws
+// layers with in place of SpriteShader and ShapeShader, which it cannot run.
+var HeadlessShaders = map[string]headless.FragmentShader{
+	SpriteShader: tintShader,
+	ShapeShader:  tintShader,
+}
+
+// tintShader multiplies the vertex color and texture by the tint, as both
+// shaders do.
+func tintShader(f *headless.Fragment) (gfx.Color, bool) {
+	c, discard := headless.DefaultShader(f)
+	if t, ok := f.Inputs["Tint"].(gfx.Color); ok {
+		c = gfx.Color{R: c.R * t.R, G: c.G * t.G, B: c.B * t.B, A: c.A * t.A}
+	}
+	return c, discard
+}
@@ -0,0 +1,389 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiledgfx
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"time"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/tiled"
+)
+
+// tileImage returns the image holding the tile with the local ID.
+func tileImage(ts *tiled.Tileset, id int) (*tiled.Image, error) {
+	if t, ok := ts.Tiles[id]; ok && t.Image != nil {
+		return t.Image, nil
+	}
+	if ts.Image == nil {
+		return nil, fmt.Errorf("tileset %q has no image for tile %d", ts.Name, id)
+	}
+	return ts.Image, nil
+}
+
+// tileQuad appends two triangles drawing the tile in the rectangle r of the
+// texture, flipped by the flip flags of gid, to the mesh, which must have one
+// set of texture coordinates. The corners are the top-left, top-right,
+// bottom-right and bottom-left ones of the tile as it appears on the map.
+func tileQuad(mesh *gfx.Mesh, tex *gfx.Texture, r image.Rectangle, gid tiled.GID, corners [4]gfx.Vec3, color gfx.Color) {
+	size := tex.Bounds.Size()
+	u0, v0 := float32(r.Min.X)/float32(size.X), float32(r.Min.Y)/float32(size.Y)
+	u1, v1 := float32(r.Max.X)/float32(size.X), float32(r.Max.Y)/float32(size.Y)
+	tc := [4]gfx.TexCoord{{U: u0, V: v0}, {U: u1, V: v0}, {U: u1, V: v1}, {U: u0, V: v1}}
+
+	// Tiled flips diagonally first, then horizontally and vertically.
+	if gid&tiled.FlipDiagonal != 0 {
+		tc[1], tc[3] = tc[3], tc[1]
+	}
+	if gid&tiled.FlipHorizontal != 0 {
+		tc[0], tc[1], tc[2], tc[3] = tc[1], tc[0], tc[3], tc[2]
+	}
+	if gid&tiled.FlipVertical != 0 {
+		tc[0], tc[1], tc[2], tc[3] = tc[3], tc[2], tc[1], tc[0]
+	}
+
+	appendQuad(mesh, corners, tc, color)
+}
+
+// hexRotate returns the GID of a tile on a hexagonal map without the flags
+// that rotate it, and the corners of the tile rotated around its center by
+// them. Tiled rotates such tiles clockwise by 60 degrees if their FlipDiagonal
+// flag is set and by 120 degrees if their RotateHex120 flag is, after flipping
+// them horizontally and vertically.
+func hexRotate(gid tiled.GID, corners [4]tiled.Vec2) (tiled.GID, [4]tiled.Vec2) {
+	var degrees float64
+	if gid&tiled.FlipDiagonal != 0 {
+		degrees += 60
+	}
+	if gid&tiled.RotateHex120 != 0 {
+		degrees += 120
+	}
+	gid &^= tiled.FlipDiagonal | tiled.RotateHex120
+	if degrees == 0 {
+		return gid, corners
+	}
+	cx := (corners[0].X + corners[2].X) / 2
+	cy := (corners[0].Y + corners[2].Y) / 2
+	s, c := math.Sincos(degrees * math.Pi / 180)
+	for i, p := range corners {
+		dx, dy := p.X-cx, p.Y-cy
+		corners[i] = tiled.Vec2{X: cx + dx*c - dy*s, Y: cy + dx*s + dy*c}
+	}
+	return gid, corners
+}
+
+// appendQuad appends two triangles drawing a textured quad to the mesh, with
+// the corners and texture coordinates in the order tileQuad takes them.
+func appendQuad(mesh *gfx.Mesh, corners [4]gfx.Vec3, tc [4]gfx.TexCoord, color gfx.Color) {
+	for _, i := range []int{0, 3, 1, 1, 3, 2} {
+		mesh.Vertices = append(mesh.Vertices, corners[i])
+		mesh.Colors = append(mesh.Colors, color)
+		mesh.TexCoords[0].Slice = append(mesh.TexCoords[0].Slice, tc[i])
+	}
+}
+
+// newTileMesh returns a new empty mesh for tileQuad.
+func newTileMesh() *gfx.Mesh {
+	mesh := gfx.NewMesh()
+	mesh.TexCoords = []gfx.TexCoordSet{{}}
+	return mesh
+}
+
+// NewObject returns a new object drawing the mesh with the shader. Layers
+// are drawn in order on the same plane, so depth testing is disabled.
+func NewObject(shader *gfx.Shader, mesh *gfx.Mesh) *gfx.Object {
+	obj := gfx.NewObject()
+	obj.State = gfx.NewState()
+	obj.AlphaMode = gfx.AlphaBlend
+	obj.DepthTest = false
+	obj.DepthWrite = false
+	obj.FaceCulling = gfx.NoFaceCulling
+	obj.Shader = shader
+	obj.Meshes = []*gfx.Mesh{mesh}
+	return obj
+}
+
+// animatedTile draws every instance of an animated tile in a layer. It holds
+// a mesh for each frame of the animation and swaps in the one of the current
+// frame, such that animating never rebuilds or reloads any mesh.
+type animatedTile struct {
+	obj    *gfx.Object
+	tile   *tiled.Tile
+	frames map[int]animationFrame // By local tile ID.
+	shown  int
+}
+
+// animationFrame is the mesh and texture drawing a frame of an animated tile.
+type animationFrame struct {
+	mesh *gfx.Mesh
+	tex  *gfx.Texture
+}
+
+// update shows the frame of the animation after the elapsed time.
+func (a *animatedTile) update(elapsed time.Duration) {
+	id := a.tile.Frame(elapsed)
+	if id == a.shown {
+		return
+	}
+	f := a.frames[id]
+	a.obj.Lock()
+	a.obj.Meshes[0] = f.mesh
+	a.obj.Textures[0] = f.tex
+	a.obj.Unlock()
+	a.shown = id
+}
+
+// chunkSize is the width and height in tiles of the chunks tile layers are
+// split into. Chunks outside of the camera's view are not drawn at all.
+const chunkSize = 32
+
+// chunkArea returns the area in tiles of the chunk holding the tile at p.
+// Chunks are aligned to the grid, even for layers of infinite maps that start
+// at negative positions.
+func chunkArea(p image.Point) image.Rectangle {
+	align := func(v int) int {
+		if v < 0 {
+			v -= chunkSize - 1
+		}
+		return v / chunkSize * chunkSize
+	}
+	x, y := align(p.X), align(p.Y)
+	return image.Rect(x, y, x+chunkSize, y+chunkSize)
+}
+
+// chunk holds the gfx objects drawing a square area of a tile layer.
+type chunk struct {
+	area image.Rectangle // The area of the chunk in tiles.
+
+	// bounds is the area the chunk's tiles cover in pixels on the map,
+	// including tiles larger than their cell.
+	bounds image.Rectangle
+
+	// objects draw the tiles in the order they overlap each other, one for
+	// each run of consecutive tiles sharing a texture or animation.
+	objects []*gfx.Object
+
+	// animated holds the animation of each of the objects that are animated.
+	animated []*animatedTile
+}
+
+// tileLayer holds the chunks of a tile layer.
+type tileLayer struct {
+	chunks []*chunk
+
+	// What the chunks are created from, to recreate them as tiles change.
+	m        *tiled.Map
+	layer    *tiled.TileLayer
+	textures *Textures
+	shader   *gfx.Shader
+}
+
+// update recreates the chunks holding the tiles at the positions, after the
+// tiles changed.
+func (l *tileLayer) update(tiles []image.Point) error {
+	changed := make(map[image.Rectangle]bool)
+	for _, p := range tiles {
+		changed[chunkArea(p)] = true
+	}
+	var chunks []*chunk
+	for _, c := range l.chunks {
+		if !changed[c.area] {
+			chunks = append(chunks, c)
+		}
+	}
+	for area := range changed {
+		c, err := newChunk(l.m, l.layer, area, l.textures, l.shader)
+		if err != nil {
+			return err
+		}
+		if c != nil {
+			chunks = append(chunks, c)
+		}
+	}
+
+	// Keep the chunks in the order they were created in.
+	sort.Slice(chunks, func(i, j int) bool {
+		a, b := chunks[i].area.Min, chunks[j].area.Min
+		return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+	})
+	l.chunks = chunks
+	return nil
+}
+
+// DrawStats are statistics about what was drawn in a frame.
+type DrawStats struct {
+	chunks, chunksDrawn int // Non-empty chunks, and those that were drawn.
+	objects             int // Objects drawn.
+}
+
+// String returns the statistics as text.
+func (s DrawStats) String() string {
+	return fmt.Sprintf("%d/%d chunks drawn (%d objects)", s.chunksDrawn, s.chunks, s.objects)
+}
+
+// draw draws the chunks of the layer that overlap the view, a rectangle in
+// pixels on the map, with their animations at the elapsed time.
+func (l *tileLayer) draw(d gfx.Device, cam gfx.Camera, view image.Rectangle, elapsed time.Duration, stats *DrawStats) {
+	for _, c := range l.chunks {
+		stats.chunks++
+		if !c.bounds.Overlaps(view) {
+			continue
+		}
+		stats.chunksDrawn++
+		for _, a := range c.animated {
+			a.update(elapsed)
+		}
+		for _, obj := range c.objects {
+			d.Draw(d.Bounds(), obj, cam)
+		}
+		stats.objects += len(c.objects)
+	}
+}
+
+// newTileLayer creates the chunks drawing the tile layer of the map.
+func newTileLayer(m *tiled.Map, l *tiled.TileLayer, textures *Textures, shader *gfx.Shader) (*tileLayer, error) {
+	tl := &tileLayer{m: m, layer: l, textures: textures, shader: shader}
+	b := l.Bounds()
+	first := chunkArea(b.Min)
+	for y := first.Min.Y; y < b.Max.Y; y += chunkSize {
+		for x := first.Min.X; x < b.Max.X; x += chunkSize {
+			c, err := newChunk(m, l, chunkArea(image.Pt(x, y)), textures, shader)
+			if err != nil {
+				return nil, err
+			}
+			if c != nil {
+				tl.chunks = append(tl.chunks, c)
+			}
+		}
+	}
+	return tl, nil
+}
+
+// newChunk creates the chunk drawing the tiles of the layer within the
+// rectangle (in tiles), or returns nil if there are none.
+func newChunk(m *tiled.Map, l *tiled.TileLayer, area image.Rectangle, textures *Textures, shader *gfx.Shader) (*chunk, error) {
+	var tiles []image.Point
+	in := area.Intersect(l.Bounds())
+	for y := in.Min.Y; y < in.Max.Y; y++ {
+		for x := in.Min.X; x < in.Max.X; x++ {
+			if l.At(x, y).ID() != 0 {
+				tiles = append(tiles, image.Pt(x, y))
+			}
+		}
+	}
+	if len(tiles) == 0 {
+		return nil, nil
+	}
+	m.SortTiles(tiles)
+
+	white := gfx.Color{R: 1, G: 1, B: 1, A: 1}
+	c := &chunk{area: area}
+
+	// quad appends the tile with the local ID at p to the mesh returned by
+	// the function for its texture.
+	quad := func(ts *tiled.Tileset, id int, gid tiled.GID, p image.Point, mesh func(tex *gfx.Texture) *gfx.Mesh) error {
+		tex, r, err := textures.tile(ts, id)
+		if err != nil {
+			return err
+		}
+
+		// Tiles larger than their cell extend up and right from its
+		// bottom-left corner.
+		cell := m.CellBounds(p.X, p.Y)
+		left := float64(cell.Min.X + ts.TileOffset.X)
+		bottom := float64(cell.Max.Y + ts.TileOffset.Y)
+		right, top := left+float64(r.Dx()), bottom-float64(r.Dy())
+		points := [4]tiled.Vec2{{X: left, Y: top}, {X: right, Y: top}, {X: right, Y: bottom}, {X: left, Y: bottom}}
+		if m.Orientation == tiled.Hexagonal {
+			gid, points = hexRotate(gid, points)
+		}
+		var corners [4]gfx.Vec3
+		min, max := points[0], points[0]
+		for i, pt := range points {
+			corners[i] = WorldPos(pt)
+			min.X, min.Y = math.Min(min.X, pt.X), math.Min(min.Y, pt.Y)
+			max.X, max.Y = math.Max(max.X, pt.X), math.Max(max.Y, pt.Y)
+		}
+		c.bounds = c.bounds.Union(image.Rect(
+			int(math.Floor(min.X)), int(math.Floor(min.Y)),
+			int(math.Ceil(max.X)), int(math.Ceil(max.Y)),
+		))
+		tileQuad(mesh(tex), tex, r, gid, corners, white)
+		return nil
+	}
+
+	// The object and animation of the current run of tiles, a new run
+	// starting whenever the texture or animation changes.
+	var (
+		run  *gfx.Object
+		anim *animatedTile
+	)
+	static := func(tex *gfx.Texture) *gfx.Mesh {
+		if run == nil || anim != nil || run.Textures[0] != tex {
+			run = NewObject(shader, newTileMesh())
+			run.Textures = []*gfx.Texture{tex}
+			c.objects = append(c.objects, run)
+			anim = nil
+		}
+		return run.Meshes[0]
+	}
+
+	for _, p := range tiles {
+		gid := l.At(p.X, p.Y)
+		ts, id := m.Tileset(gid)
+		if ts == nil {
+			return nil, fmt.Errorf("layer %q: no tileset for GID %d at %d,%d", l.Name, gid.ID(), p.X, p.Y)
+		}
+
+		t, ok := ts.Tiles[id]
+		if !ok || len(t.Animation) == 0 {
+			if err := quad(ts, id, gid, p, static); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Add the tile to the mesh of each frame of its animation. The meshes
+		// of the first frame are put in the object once they are complete.
+		if anim == nil || anim.tile != t {
+			run = NewObject(shader, nil)
+			run.Textures = []*gfx.Texture{nil}
+			c.objects = append(c.objects, run)
+			anim = &animatedTile{obj: run, tile: t, frames: make(map[int]animationFrame), shown: -1}
+			c.animated = append(c.animated, anim)
+		}
+		a := anim
+		added := make(map[int]bool, len(t.Animation))
+		for _, f := range t.Animation {
+			frame := f.TileID
+			if added[frame] {
+				continue
+			}
+			added[frame] = true
+			err := quad(ts, frame, gid, p, func(tex *gfx.Texture) *gfx.Mesh {
+				af, ok := a.frames[frame]
+				if !ok {
+					af = animationFrame{mesh: newTileMesh(), tex: tex}
+					a.frames[frame] = af
+				}
+				return af.mesh
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, a := range c.animated {
+		first := a.frames[a.tile.Animation[0].TileID]
+		a.obj.Meshes[0] = first.mesh
+		a.obj.Textures[0] = first.tex
+	}
+	return c, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiledgfx
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/headless"
+	"azul3d.org/examples/tiled"
+)
+
+func TestHexRotate(t *testing.T) {
+	// A 2x2 tile centered on the origin.
+	corners := [4]tiled.Vec2{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 1}, {X: -1, Y: 1}}
+	tests := []struct {
+		gid     tiled.GID
+		want    tiled.GID
+		degrees float64
+	}{
+		{7, 7, 0},
+		{7 | tiled.FlipHorizontal, 7 | tiled.FlipHorizontal, 0},
+		{7 | tiled.FlipDiagonal, 7, 60},
+		{7 | tiled.RotateHex120 | tiled.FlipVertical, 7 | tiled.FlipVertical, 120},
+		{7 | tiled.FlipDiagonal | tiled.RotateHex120, 7, 180},
+	}
+	for _, tst := range tests {
+		gid, got := hexRotate(tst.gid, corners)
+		if gid != tst.want {
+			t.Errorf("%#x: got GID %#x, want %#x", uint32(tst.gid), uint32(gid), uint32(tst.want))
+		}
+		// Clockwise on the map, where Y points down.
+		s, c := math.Sincos(tst.degrees * math.Pi / 180)
+		for i, p := range corners {
+			want := tiled.Vec2{X: p.X*c - p.Y*s, Y: p.X*s + p.Y*c}
+			if math.Abs(got[i].X-want.X) > 1e-9 || math.Abs(got[i].Y-want.Y) > 1e-9 {
+				t.Errorf("%#x: corner %d at %v, want %v", uint32(tst.gid), i, got[i], want)
+			}
+		}
+	}
+
+	// The top-left corner turned by 60 degrees moves right, towards the
+	// top-right one.
+	_, got := hexRotate(tiled.FlipDiagonal, corners)
+	if got[0].X <= corners[0].X || got[0].Y >= 0 {
+		t.Errorf("top-left corner rotated to %v, want it up and to the right", got[0])
+	}
+}
+
+// countDevice is a headless device that counts the objects drawn with it
+// instead of drawing them.
+type countDevice struct {
+	*headless.Device
+	draws int
+}
+
+// Draw implements the gfx.Canvas interface.
+func (d *countDevice) Draw(r image.Rectangle, o *gfx.Object, cam gfx.Camera) {
+	d.draws++
+}
+
+// bigLayer returns a 1000x1000 layer of 16x16 tiles, with a tile at the top
+// left and bottom right corners of each chunk, such that the chunks cover
+// their whole area.
+func bigLayer(t testing.TB) *tileLayer {
+	const size = 1000
+	m := &tiled.Map{
+		Orientation: tiled.Orthogonal,
+		Width:       size,
+		Height:      size,
+		TileWidth:   16,
+		TileHeight:  16,
+		Tilesets: []*tiled.Tileset{{
+			FirstGID:   1,
+			Name:       "a",
+			TileWidth:  16,
+			TileHeight: 16,
+			TileCount:  4,
+			Columns:    2,
+			Image:      &tiled.Image{Source: "a.png", Path: "a.png", Width: 32, Height: 32},
+		}},
+	}
+	l := &tiled.TileLayer{Width: size, Height: size, Tiles: make([]tiled.GID, size*size)}
+	last := func(v int) int {
+		if v+chunkSize > size {
+			return size - 1
+		}
+		return v + chunkSize - 1
+	}
+	for y := 0; y < size; y += chunkSize {
+		for x := 0; x < size; x += chunkSize {
+			l.Set(x, y, 1)
+			l.Set(last(x), last(y), 2)
+		}
+	}
+	textures := &Textures{textures: map[string]*gfx.Texture{
+		"a.png": newMapTexture(image.NewRGBA(image.Rect(0, 0, 32, 32))),
+	}}
+	tl, err := newTileLayer(m, l, textures, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tl
+}
+
+func TestTileLayerCulling(t *testing.T) {
+	l := bigLayer(t)
+
+	// The layer is 32x32 chunks of 512x512 pixels, the last row and column of
+	// which are 128 pixels wide.
+	tests := []struct {
+		view  image.Rectangle
+		drawn int
+	}{
+		{image.Rect(0, 0, 16000, 16000), 32 * 32},
+		{image.Rect(-1000, -1000, 20000, 20000), 32 * 32},
+		{image.Rect(0, 0, 512, 512), 1},
+		{image.Rect(0, 0, 513, 513), 4},
+		{image.Rect(511, 511, 513, 513), 4},
+		{image.Rect(1000, 1000, 1800, 1400), 3 * 2},
+		{image.Rect(0, 4200, 16000, 4300), 32},
+		{image.Rect(0, 4000, 16000, 4100), 32 * 2}, // Across rows, at 4096.
+		{image.Rect(15990, 15990, 16010, 16010), 1},
+		{image.Rect(-100, -100, 1, 1), 1},
+		{image.Rect(-100, -100, 0, 0), 0},
+		{image.Rect(16000, 0, 17000, 500), 0},
+		{image.Rectangle{}, 0},
+	}
+	d := &countDevice{Device: headless.New(image.Rect(0, 0, 1, 1), gfx.Precision{})}
+	for _, tst := range tests {
+		d.draws = 0
+		var stats DrawStats
+		l.draw(d, nil, tst.view, 0, &stats)
+		if stats.chunks != 32*32 || stats.chunksDrawn != tst.drawn {
+			t.Errorf("view %v: %v, want %d/%d chunks drawn", tst.view, stats, tst.drawn, 32*32)
+		}
+		if d.draws != stats.objects || stats.objects != tst.drawn {
+			t.Errorf("view %v: drew %d objects (%d counted), want one per chunk drawn", tst.view, d.draws, stats.objects)
+		}
+	}
+}
+
+func BenchmarkTileLayerDraw(b *testing.B) {
+	l := bigLayer(b)
+	d := &countDevice{Device: headless.New(image.Rect(0, 0, 1, 1), gfx.Precision{})}
+	view := image.Rect(4000, 4000, 4800, 4600)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var stats DrawStats
+		l.draw(d, nil, view, 0, &stats)
+	}
+}
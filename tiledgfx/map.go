@@ -0,0 +1,230 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiledgfx
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"azul3d.org/engine/gfx"
+	"azul3d.org/engine/lmath"
+
+	"azul3d.org/examples/abs"
+	"azul3d.org/examples/tiled"
+)
+
+// Shaders opens the shaders that layers are drawn with (SpriteShader and
+// ShapeShader), once per shader and tint. The tint is an input of the shader,
+// which is shared by every object drawn with it, so layers with different
+// tints need their own.
+type Shaders struct {
+	shaders map[shaderKey]*gfx.Shader
+}
+
+type shaderKey struct {
+	name string
+	tint gfx.Color
+}
+
+// Open returns the shader with the given name, tinting everything drawn with
+// it by the color.
+func (c *Shaders) Open(name string, tint gfx.Color) (*gfx.Shader, error) {
+	key := shaderKey{name, tint}
+	if shader, ok := c.shaders[key]; ok {
+		return shader, nil
+	}
+	shader, err := abs.OpenShader(name)
+	if err != nil {
+		return nil, err
+	}
+	shader.Inputs["Tint"] = tint
+	if c.shaders == nil {
+		c.shaders = make(map[shaderKey]*gfx.Shader)
+	}
+	c.shaders[key] = shader
+	return shader, nil
+}
+
+// Layer draws a layer of the map of any kind, placed, tinted and faded as
+// Tiled draws it.
+type Layer struct {
+	// Source is the layer of the map that is drawn.
+	Source tiled.Layer
+
+	comp   tiled.Composite
+	origin tiled.Vec2 // The parallax origin of the map.
+
+	// One of the following is set, depending on the kind of the layer.
+	tiles   *tileLayer
+	objects *objectLayer
+	image   *gfx.Object
+
+	all   []*gfx.Object // Every object of the layer.
+	shift tiled.Vec2    // The offset that the objects were last moved to.
+}
+
+// Shift returns the offset that the layer is drawn at, by its own offset and
+// parallax scrolling, with the center of the view at the given position.
+func (l *Layer) Shift(center tiled.Vec2) tiled.Vec2 {
+	return l.comp.Shift(l.origin, center)
+}
+
+// Draw draws the layer with the center of the view at the given position,
+// and view the rectangle seen by the camera, in pixels on the map. Animated
+// tiles show the frame of their animation after the elapsed time, and the
+// number of chunks and objects drawn is added to stats.
+func (l *Layer) Draw(d gfx.Device, cam gfx.Camera, center tiled.Vec2, view image.Rectangle, elapsed time.Duration, mode ObjectMode, stats *DrawStats) {
+	// Move the objects of the layer by its offset and parallax scrolling,
+	// which changes only as the camera moves.
+	shift := l.Shift(center)
+	if shift != l.shift {
+		l.move(shift)
+	}
+
+	switch {
+	case l.tiles != nil:
+		// Cull chunks against the view as seen from the layer.
+		p := image.Pt(int(math.Floor(shift.X)), int(math.Floor(shift.Y)))
+		l.tiles.draw(d, cam, view.Sub(p).Inset(-1), elapsed, stats)
+	case l.objects != nil:
+		l.objects.draw(d, cam, mode)
+	case l.image != nil:
+		d.Draw(d.Bounds(), l.image, cam)
+	}
+}
+
+// move moves every object of the layer to the offset.
+func (l *Layer) move(shift tiled.Vec2) {
+	pos := lmath.Vec3{X: shift.X, Y: 0, Z: -shift.Y}
+	for _, obj := range l.all {
+		obj.SetPos(pos)
+	}
+	l.shift = shift
+}
+
+// UpdateTiles redraws the tiles at the positions of a tile layer, after they
+// changed.
+func (l *Layer) UpdateTiles(tiles []image.Point) error {
+	if err := l.tiles.update(tiles); err != nil {
+		return err
+	}
+	l.all = l.all[:0]
+	for _, c := range l.tiles.chunks {
+		l.all = append(l.all, c.objects...)
+	}
+	l.move(l.shift)
+	return nil
+}
+
+// NewLayers creates the layers drawing the map, in the order they must be
+// drawn. Group layers are flattened into the layers they hold, and hidden
+// layers are left out.
+func NewLayers(m *tiled.Map, textures *Textures, shaders *Shaders) ([]*Layer, error) {
+	var (
+		layers []*Layer
+		add    func(ls []tiled.Layer) error
+	)
+	add = func(ls []tiled.Layer) error {
+		for _, tl := range ls {
+			comp := tl.Info().Composite()
+			if !comp.Visible {
+				continue
+			}
+			if g, ok := tl.(*tiled.Group); ok {
+				if err := add(g.Layers); err != nil {
+					return err
+				}
+				continue
+			}
+			l, err := newLayer(m, tl, comp, textures, shaders)
+			if err != nil {
+				return err
+			}
+			layers = append(layers, l)
+		}
+		return nil
+	}
+	if err := add(m.Layers); err != nil {
+		return nil, err
+	}
+	return layers, nil
+}
+
+// newLayer creates the layer drawing the tile, object or image layer of the
+// map, with the composite attributes.
+func newLayer(m *tiled.Map, tl tiled.Layer, comp tiled.Composite, textures *Textures, shaders *Shaders) (*Layer, error) {
+	// Opacity and tint are applied by the shaders.
+	tint := gfx.Color{
+		R: float32(comp.Tint.R) / 255,
+		G: float32(comp.Tint.G) / 255,
+		B: float32(comp.Tint.B) / 255,
+		A: float32(comp.Tint.A) / 255 * float32(comp.Opacity),
+	}
+	spriteShader, err := shaders.Open(SpriteShader, tint)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Layer{Source: tl, comp: comp, origin: m.ParallaxOrigin}
+	switch tl := tl.(type) {
+	case *tiled.TileLayer:
+		if l.tiles, err = newTileLayer(m, tl, textures, spriteShader); err != nil {
+			return nil, err
+		}
+		for _, c := range l.tiles.chunks {
+			l.all = append(l.all, c.objects...)
+		}
+
+	case *tiled.ObjectGroup:
+		shapeShader, err := shaders.Open(ShapeShader, tint)
+		if err != nil {
+			return nil, err
+		}
+		if l.objects, err = newObjectLayer(m, tl, textures, shapeShader, spriteShader); err != nil {
+			return nil, err
+		}
+		l.all = append(l.all, l.objects.sprites...)
+		if l.objects.shapes != nil {
+			l.all = append(l.all, l.objects.shapes)
+		}
+
+	case *tiled.ImageLayer:
+		if l.image, err = newImageLayer(tl, textures, spriteShader); err != nil {
+			return nil, err
+		}
+		if l.image != nil {
+			l.all = append(l.all, l.image)
+		}
+	}
+	return l, nil
+}
+
+// newImageLayer creates the object drawing the image of the image layer with
+// its top-left corner at the origin, or returns nil if it has no image.
+func newImageLayer(l *tiled.ImageLayer, textures *Textures, shader *gfx.Shader) (*gfx.Object, error) {
+	if l.Image == nil {
+		return nil, nil
+	}
+	tex, err := textures.open(l.Image.Path)
+	if err != nil {
+		return nil, err
+	}
+	size := tex.Bounds.Size()
+	w, h := float64(size.X), float64(size.Y)
+	corners := [4]gfx.Vec3{
+		WorldPos(tiled.Vec2{X: 0, Y: 0}),
+		WorldPos(tiled.Vec2{X: w, Y: 0}),
+		WorldPos(tiled.Vec2{X: w, Y: h}),
+		WorldPos(tiled.Vec2{X: 0, Y: h}),
+	}
+	tc := [4]gfx.TexCoord{{U: 0, V: 0}, {U: 1, V: 0}, {U: 1, V: 1}, {U: 0, V: 1}}
+	mesh := newTileMesh()
+	appendQuad(mesh, corners, tc, gfx.Color{R: 1, G: 1, B: 1, A: 1})
+
+	obj := NewObject(shader, mesh)
+	obj.Textures = []*gfx.Texture{tex}
+	return obj, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiledgfx
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"math"
+	"sort"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/tiled"
+)
+
+// ObjectMode is what is drawn of the object layers.
+type ObjectMode int
+
+// The object modes.
+const (
+	ObjectsAll     ObjectMode = iota // Tile sprites and debug shapes.
+	ObjectsSprites                   // Tile sprites only.
+	ObjectsHidden                    // Nothing.
+)
+
+// String returns a description of the mode.
+func (m ObjectMode) String() string {
+	switch m {
+	case ObjectsAll:
+		return "sprites and shapes"
+	case ObjectsSprites:
+		return "sprites"
+	default:
+		return "hidden"
+	}
+}
+
+// defaultObjectColor is the color of the shapes of object groups without a
+// color, the same one Tiled uses.
+var defaultObjectColor = gfx.Color{R: 0xa0 / 255.0, G: 0xa0 / 255.0, B: 0xa4 / 255.0, A: 1}
+
+// ellipseSegments is the number of line segments that ellipses are drawn
+// with, and pointRadius the radius in pixels of the diamond points are drawn
+// as.
+const (
+	ellipseSegments = 32
+	pointRadius     = 4
+)
+
+// WorldPos returns the world position of a position in pixels on the map: the
+// top-left corner of the map is at the origin, with Y pointing down the Z axis.
+func WorldPos(p tiled.Vec2) gfx.Vec3 {
+	return gfx.Vec3{X: float32(p.X), Y: 0, Z: float32(-p.Y)}
+}
+
+// objectLayer holds the gfx objects drawing an object group.
+type objectLayer struct {
+	shapes  *gfx.Object   // Outlines of every object, nil if there are none.
+	sprites []*gfx.Object // Tile objects, one per run of objects sharing a texture.
+}
+
+// draw draws the layer in the given mode.
+func (l *objectLayer) draw(d gfx.Device, cam gfx.Camera, mode ObjectMode) {
+	if mode == ObjectsHidden {
+		return
+	}
+	for _, s := range l.sprites {
+		d.Draw(d.Bounds(), s, cam)
+	}
+	if mode == ObjectsAll && l.shapes != nil {
+		d.Draw(d.Bounds(), l.shapes, cam)
+	}
+}
+
+// Textures loads the images of a map as textures, once per image.
+type Textures struct {
+	// FS is the file system that the images are read from, that of the map.
+	FS fs.FS
+
+	// Atlas holds the tiles of the map packed into fewer textures, nil if
+	// they are drawn from the images of their tilesets. Layers must be
+	// created again after it is set.
+	Atlas *Atlas
+
+	textures map[string]*gfx.Texture
+}
+
+// tile returns the texture holding the tile with the local ID, and the
+// rectangle of the tile within it.
+func (c *Textures) tile(ts *tiled.Tileset, id int) (*gfx.Texture, image.Rectangle, error) {
+	img, err := tileImage(ts, id)
+	if err != nil {
+		return nil, image.Rectangle{}, err
+	}
+	r := ts.TileRect(id)
+	if c.Atlas != nil {
+		if e, ok := c.Atlas.tiles[atlasKey{img.Path, r}]; ok {
+			return e.tex, e.rect, nil
+		}
+	}
+	tex, err := c.open(img.Path)
+	return tex, r, err
+}
+
+// open returns the texture of the image with the given path.
+func (c *Textures) open(name string) (*gfx.Texture, error) {
+	if tex, ok := c.textures[name]; ok {
+		return tex, nil
+	}
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	tex := newMapTexture(img)
+	if c.textures == nil {
+		c.textures = make(map[string]*gfx.Texture)
+	}
+	c.textures[name] = tex
+	return tex, nil
+}
+
+// newMapTexture returns a new texture of the image, sampled as pixel art.
+func newMapTexture(img image.Image) *gfx.Texture {
+	tex := gfx.NewTexture()
+	tex.Source = img
+	tex.Bounds = img.Bounds()
+	tex.MinFilter = gfx.Nearest
+	tex.MagFilter = gfx.Nearest
+	tex.WrapU = gfx.Clamp
+	tex.WrapV = gfx.Clamp
+	return tex
+}
+
+// outline returns the outline of the object in pixels relative to its
+// position, before rotation, and whether it is closed.
+func outline(o *tiled.Object) (points []tiled.Vec2, closed bool) {
+	switch o.Shape {
+	case tiled.Ellipse:
+		rx, ry := o.Width/2, o.Height/2
+		for i := 0; i < ellipseSegments; i++ {
+			a := 2 * math.Pi * float64(i) / ellipseSegments
+			points = append(points, tiled.Vec2{X: rx + rx*math.Cos(a), Y: ry + ry*math.Sin(a)})
+		}
+		return points, true
+	case tiled.Point:
+		r := float64(pointRadius)
+		return []tiled.Vec2{{X: 0, Y: -r}, {X: r, Y: 0}, {X: 0, Y: r}, {X: -r, Y: 0}}, true
+	case tiled.Polygon:
+		return o.Points, true
+	case tiled.Polyline:
+		return o.Points, false
+	case tiled.TileShape:
+		// Tile objects extend upwards from their position.
+		return []tiled.Vec2{{X: 0, Y: -o.Height}, {X: o.Width, Y: -o.Height}, {X: o.Width, Y: 0}, {X: 0, Y: 0}}, true
+	default:
+		return []tiled.Vec2{{X: 0, Y: 0}, {X: o.Width, Y: 0}, {X: o.Width, Y: o.Height}, {X: 0, Y: o.Height}}, true
+	}
+}
+
+// newObjectLayer creates the gfx objects drawing the object group of the map:
+// tile objects are drawn as sprites, and every object is outlined with lines
+// in the group's color.
+func newObjectLayer(m *tiled.Map, g *tiled.ObjectGroup, textures *Textures, shapeShader, spriteShader *gfx.Shader) (*objectLayer, error) {
+	l := &objectLayer{}
+	color := defaultObjectColor
+	if g.Color.A != 0 {
+		color = gfx.Color{
+			R: float32(g.Color.R) / 255,
+			G: float32(g.Color.G) / 255,
+			B: float32(g.Color.B) / 255,
+			A: 1,
+		}
+	}
+
+	objects := make([]*tiled.Object, 0, len(g.Objects))
+	for _, o := range g.Objects {
+		if o.Visible {
+			objects = append(objects, o)
+		}
+	}
+	if g.DrawOrder == "topdown" {
+		sort.SliceStable(objects, func(i, j int) bool {
+			a := m.Project(tiled.Vec2{X: objects[i].X, Y: objects[i].Y})
+			b := m.Project(tiled.Vec2{X: objects[j].X, Y: objects[j].Y})
+			return a.Y < b.Y
+		})
+	}
+
+	shapes := gfx.NewMesh()
+	shapes.Primitive = gfx.Lines
+	white := gfx.Color{R: 1, G: 1, B: 1, A: 1}
+	for _, o := range objects {
+		points, closed := outline(o)
+		segments := len(points) - 1
+		if closed && len(points) > 2 {
+			segments = len(points)
+		}
+		for i := 0; i < segments; i++ {
+			a, b := points[i], points[(i+1)%len(points)]
+			shapes.Vertices = append(shapes.Vertices, WorldPos(m.Place(o, a)), WorldPos(m.Place(o, b)))
+			shapes.Colors = append(shapes.Colors, color, color)
+		}
+
+		if o.Shape != tiled.TileShape {
+			continue
+		}
+		ts, id := m.Tileset(o.GID)
+		if ts == nil {
+			return nil, fmt.Errorf("object %d: no tileset for GID %d", o.ID, o.GID.ID())
+		}
+		tex, r, err := textures.tile(ts, id)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %v", o.ID, err)
+		}
+		var corners [4]gfx.Vec3
+		for i := range corners {
+			p := m.Place(o, points[i])
+			p.X += float64(ts.TileOffset.X)
+			p.Y += float64(ts.TileOffset.Y)
+			corners[i] = WorldPos(p)
+		}
+
+		// Consecutive objects sharing a texture are drawn at once, a new
+		// sprite starting whenever it changes such that the draw order is
+		// kept across tilesets.
+		n := len(l.sprites)
+		if n == 0 || l.sprites[n-1].Textures[0] != tex {
+			sprite := NewObject(spriteShader, newTileMesh())
+			sprite.Textures = []*gfx.Texture{tex}
+			l.sprites = append(l.sprites, sprite)
+			n++
+		}
+		tileQuad(l.sprites[n-1].Meshes[0], tex, r, o.GID, corners, white)
+	}
+
+	if len(shapes.Vertices) > 0 {
+		l.shapes = NewObject(shapeShader, shapes)
+	}
+	return l, nil
+}
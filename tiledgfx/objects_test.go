@@ -0,0 +1,99 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiledgfx
+
+import (
+	"image"
+	"testing"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/tiled"
+)
+
+func TestObjectLayerSprites(t *testing.T) {
+	// Two tilesets of four 16x16 tiles each, the second one drawn 3 pixels
+	// right and 2 up.
+	tileset := func(name string, firstGID uint32, offset image.Point) *tiled.Tileset {
+		return &tiled.Tileset{
+			FirstGID:   firstGID,
+			Name:       name,
+			TileWidth:  16,
+			TileHeight: 16,
+			TileCount:  4,
+			Columns:    2,
+			TileOffset: offset,
+			Image:      &tiled.Image{Source: name, Path: name, Width: 32, Height: 32},
+		}
+	}
+	m := &tiled.Map{
+		Orientation: tiled.Orthogonal,
+		Width:       10,
+		Height:      10,
+		TileWidth:   16,
+		TileHeight:  16,
+		Tilesets:    []*tiled.Tileset{tileset("a.png", 1, image.Point{}), tileset("b.png", 5, image.Pt(3, -2))},
+	}
+	texA := newMapTexture(image.NewRGBA(image.Rect(0, 0, 32, 32)))
+	texB := newMapTexture(image.NewRGBA(image.Rect(0, 0, 32, 32)))
+	textures := &Textures{textures: map[string]*gfx.Texture{"a.png": texA, "b.png": texB}}
+
+	// In topdown order the objects alternate between the tilesets, and the
+	// last two share one.
+	object := func(id int, gid tiled.GID, y float64) *tiled.Object {
+		return &tiled.Object{ID: id, Shape: tiled.TileShape, X: 10, Y: y, Width: 16, Height: 16, GID: gid, Visible: true}
+	}
+	g := &tiled.ObjectGroup{
+		DrawOrder: "topdown",
+		Objects: []*tiled.Object{
+			object(1, 2, 50),
+			object(2, 1, 20),
+			object(3, 6, 40),
+			object(4, 5, 30),
+			object(5, 3, 60),
+		},
+	}
+
+	l, err := newObjectLayer(m, g, textures, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		tex    *gfx.Texture
+		quads  int
+		bottom float32 // Of the first quad, as world Z.
+		left   float32
+	}{
+		{texA, 1, -20, 10},
+		{texB, 2, -30 + 2, 10 + 3},
+		{texA, 2, -50, 10},
+	}
+	if len(l.sprites) != len(want) {
+		t.Fatalf("got %d sprites, want %d", len(l.sprites), len(want))
+	}
+	for i, w := range want {
+		s := l.sprites[i]
+		if s.Textures[0] != w.tex {
+			t.Errorf("sprite %d: wrong texture", i)
+		}
+		v := s.Meshes[0].Vertices
+		if len(v) != w.quads*6 {
+			t.Errorf("sprite %d: got %d vertices, want %d", i, len(v), w.quads*6)
+			continue
+		}
+		minX, minZ := v[0].X, v[0].Z
+		for _, p := range v[:6] {
+			if p.X < minX {
+				minX = p.X
+			}
+			if p.Z < minZ {
+				minZ = p.Z
+			}
+		}
+		if minX != w.left || minZ != w.bottom {
+			t.Errorf("sprite %d: bottom-left corner at %v,%v, want %v,%v", i, minX, minZ, w.left, w.bottom)
+		}
+	}
+}
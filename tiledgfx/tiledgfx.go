@@ -0,0 +1,20 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tiledgfx draws the layers of maps read by package tiled with any
+// gfx.Device, as Tiled draws them.
+//
+// Layers are drawn on the XZ plane as seen by an orthographic camera looking
+// down the Y axis, with the top-left corner of the map at the origin and one
+// unit per pixel of the map (see WorldPos). It is shared by the azul3d_tmx
+// example, which draws maps in a window, and azul3d_tmxtool, which renders
+// them offscreen with a headless device.
+package tiledgfx
+
+// The names of the shaders that layers are drawn with, as opened by Shaders.
+// Both multiply what they draw by the Tint input of the shader.
+const (
+	SpriteShader = "azul3d_tmx/sprite" // Textured tiles and images.
+	ShapeShader  = "azul3d_tmx/shape"  // Lines colored by vertex.
+)
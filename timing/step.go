@@ -0,0 +1,77 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timing
+
+import "time"
+
+// Ticker reports when an interval of clock time has passed. Unlike a
+// time.Ticker it follows the frame clock, so it replays identically with a
+// fixed clock.
+type Ticker struct {
+	c        Clock
+	interval time.Duration
+	next     time.Duration
+}
+
+// NewTicker returns a new ticker that first ticks one interval from now.
+func NewTicker(c Clock, interval time.Duration) *Ticker {
+	return &Ticker{
+		c:        c,
+		interval: interval,
+		next:     c.Time() + interval,
+	}
+}
+
+// Ticked reports whether the interval has passed since the last tick. It
+// should be called once per frame; if several intervals have passed it ticks
+// only once.
+func (t *Ticker) Ticked() bool {
+	now := t.c.Time()
+	if now < t.next {
+		return false
+	}
+	t.next += t.interval
+	if t.next <= now {
+		t.next = now + t.interval
+	}
+	return true
+}
+
+// Stepper runs updates at a fixed timestep, independent of the frame rate.
+// Rendering then interpolates between the last two updates using the factor
+// returned by Advance.
+type Stepper struct {
+	// Step is the time in seconds each update advances the simulation by. It
+	// must be positive.
+	Step float64
+
+	// MaxSteps is the maximum number of updates run per frame, such that a
+	// slow frame cannot cause ever slower ones. Zero means no limit.
+	MaxSteps int
+
+	acc float64
+}
+
+// Advance advances the stepper by dt seconds (usually Clock.Dt), calling
+// update once for each whole step that has passed. It returns how far, from
+// zero to one, the current time is between the last update and the next one.
+//
+// Like time.NewTicker with a non-positive interval, it panics if Step is not
+// positive.
+func (s *Stepper) Advance(dt float64, update func(step float64)) (alpha float64) {
+	if !(s.Step > 0) {
+		panic("timing: non-positive Stepper.Step")
+	}
+	s.acc += dt
+	for n := 0; s.acc >= s.Step; n++ {
+		if s.MaxSteps > 0 && n == s.MaxSteps {
+			s.acc = 0
+			break
+		}
+		update(s.Step)
+		s.acc -= s.Step
+	}
+	return s.acc / s.Step
+}
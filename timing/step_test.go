@@ -0,0 +1,124 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timing
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testClock is a clock set by hand.
+type testClock struct {
+	t time.Duration
+}
+
+func (c *testClock) Dt() float64         { return 0 }
+func (c *testClock) Time() time.Duration { return c.t }
+func (c *testClock) FrameCount() uint64  { return 0 }
+
+func TestTicker(t *testing.T) {
+	c := &testClock{}
+	tk := NewTicker(c, 100*time.Millisecond)
+	tests := []struct {
+		t    time.Duration
+		want bool
+	}{
+		{0, false},
+		{50 * time.Millisecond, false},
+		{100 * time.Millisecond, true},
+		{100 * time.Millisecond, false},
+		{199 * time.Millisecond, false},
+		{210 * time.Millisecond, true},
+		// Ticks stay on the interval, not on the frame that ticked.
+		{300 * time.Millisecond, true},
+		// Several intervals passing tick once, and the next tick is an
+		// interval later.
+		{550 * time.Millisecond, true},
+		{600 * time.Millisecond, false},
+		{649 * time.Millisecond, false},
+		{650 * time.Millisecond, true},
+	}
+	for _, tst := range tests {
+		c.t = tst.t
+		if got := tk.Ticked(); got != tst.want {
+			t.Errorf("Ticked at %v = %v, want %v", tst.t, got, tst.want)
+		}
+	}
+}
+
+func TestStepper(t *testing.T) {
+	tests := []struct {
+		name     string
+		step     float64
+		maxSteps int
+		dts      []float64 // Frame deltas passed to Advance in turn.
+		steps    []int     // The number of updates of each frame.
+		alphas   []float64 // The factor returned for each frame.
+	}{
+		{
+			name:   "less than a step",
+			step:   0.25,
+			dts:    []float64{0.125, 0.0625, 0.0625},
+			steps:  []int{0, 0, 1},
+			alphas: []float64{0.5, 0.75, 0},
+		},
+		{
+			name:   "several steps",
+			step:   0.25,
+			dts:    []float64{0.625, 0.125, 1},
+			steps:  []int{2, 1, 4},
+			alphas: []float64{0.5, 0, 0},
+		},
+		{
+			name:     "capped",
+			step:     0.25,
+			maxSteps: 3,
+			dts:      []float64{2, 0.125, 1},
+			steps:    []int{3, 0, 3},
+			alphas:   []float64{0, 0.5, 0},
+		},
+		{
+			name:     "under the cap",
+			step:     0.25,
+			maxSteps: 3,
+			dts:      []float64{0.5, 0.875},
+			steps:    []int{2, 3},
+			alphas:   []float64{0, 0.5},
+		},
+	}
+	for _, tst := range tests {
+		s := &Stepper{Step: tst.step, MaxSteps: tst.maxSteps}
+		for i, dt := range tst.dts {
+			n := 0
+			alpha := s.Advance(dt, func(step float64) {
+				if step != tst.step {
+					t.Errorf("%s: frame %d updated by %v, want %v", tst.name, i, step, tst.step)
+				}
+				n++
+			})
+			if n != tst.steps[i] {
+				t.Errorf("%s: frame %d ran %d updates, want %d", tst.name, i, n, tst.steps[i])
+			}
+			if math.Abs(alpha-tst.alphas[i]) > 1e-9 {
+				t.Errorf("%s: frame %d alpha %v, want %v", tst.name, i, alpha, tst.alphas[i])
+			}
+		}
+	}
+}
+
+func TestStepperPanics(t *testing.T) {
+	for _, step := range []float64{0, -1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Advance with Step %v did not panic", step)
+				}
+			}()
+			s := &Stepper{Step: step}
+			s.Advance(1, func(float64) {})
+		}()
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package timing provides the frame clock and random numbers the examples
+// animate with, such that a run can be replayed frame-for-frame.
+//
+// By default the device's clock (i.e. wall-clock time) is used. If the
+// $AZUL3D_FIXED_DT environment variable is set to a duration (e.g. "16ms"), or
+// Fix is called, every frame instead takes exactly that long. Random numbers
+// come from Rand, which is seeded with $AZUL3D_SEED if set.
+package timing
+
+import (
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"azul3d.org/engine/gfx"
+)
+
+const (
+	// EnvFixedDelta is the environment variable holding the fixed frame
+	// delta, see Fix.
+	EnvFixedDelta = "AZUL3D_FIXED_DT"
+
+	// EnvSeed is the environment variable holding the random seed, see Seed.
+	EnvSeed = "AZUL3D_SEED"
+)
+
+// Clock measures the time of rendered frames. It is implemented by the
+// device's *clock.Clock and by *Fixed.
+type Clock interface {
+	// Dt returns the time in seconds that the last frame took.
+	Dt() float64
+
+	// Time returns the time elapsed since the first frame.
+	Time() time.Duration
+
+	// FrameCount returns the number of frames rendered so far.
+	FrameCount() uint64
+}
+
+var (
+	lock       sync.Mutex
+	fixedDelta time.Duration
+	fixedInit  bool
+	random     *rand.Rand
+)
+
+// Fix makes For return clocks that advance by the given delta each frame, a
+// zero delta switches back to the device's clock. It must be called before
+// For to take effect.
+func Fix(delta time.Duration) {
+	lock.Lock()
+	defer lock.Unlock()
+	fixedInit = true
+	fixedDelta = delta
+}
+
+// For returns the clock that should be used for animating things rendered
+// with the given device: the device's own clock, or a Fixed one (see Fix).
+func For(d gfx.Device) Clock {
+	lock.Lock()
+	defer lock.Unlock()
+	if !fixedInit {
+		fixedInit = true
+		if s := os.Getenv(EnvFixedDelta); len(s) > 0 {
+			delta, err := time.ParseDuration(s)
+			if err != nil {
+				log.Fatalf("timing: $%s: %v", EnvFixedDelta, err)
+			}
+			fixedDelta = delta
+		}
+	}
+	if fixedDelta == 0 {
+		return d.Clock()
+	}
+	return NewFixed(d, fixedDelta)
+}
+
+// Fixed is a clock whose frames each take exactly Delta, no matter how long
+// the device actually took to render them.
+type Fixed struct {
+	// Delta is the duration of each frame.
+	Delta time.Duration
+
+	d gfx.Device
+}
+
+// NewFixed returns a new fixed clock counting the frames rendered by the
+// given device.
+func NewFixed(d gfx.Device, delta time.Duration) *Fixed {
+	return &Fixed{Delta: delta, d: d}
+}
+
+// Dt implements the Clock interface.
+func (f *Fixed) Dt() float64 {
+	return f.Delta.Seconds()
+}
+
+// Time implements the Clock interface.
+func (f *Fixed) Time() time.Duration {
+	return time.Duration(f.FrameCount()) * f.Delta
+}
+
+// FrameCount implements the Clock interface.
+func (f *Fixed) FrameCount() uint64 {
+	return f.d.Clock().FrameCount()
+}
+
+// Seed seeds the random number source returned by Rand. It must be called
+// before Rand to take effect.
+func Seed(seed int64) {
+	lock.Lock()
+	defer lock.Unlock()
+	random = rand.New(&lockedSource{src: rand.NewSource(seed)})
+}
+
+// Rand returns the random number source the examples should use instead of
+// the global one, it is safe for concurrent use. Unless Seed was called it is
+// seeded with $AZUL3D_SEED or, if unset, the current time; the seed is logged
+// so that the run can be replayed.
+func Rand() *rand.Rand {
+	lock.Lock()
+	defer lock.Unlock()
+	if random != nil {
+		return random
+	}
+	seed := time.Now().UnixNano()
+	if s := os.Getenv(EnvSeed); len(s) > 0 {
+		var err error
+		seed, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			log.Fatalf("timing: $%s: %v", EnvSeed, err)
+		}
+	} else {
+		log.Printf("timing: random seed %d (set $%s to replay)\n", seed, EnvSeed)
+	}
+	random = rand.New(&lockedSource{src: rand.NewSource(seed)})
+	return random
+}
+
+// lockedSource is a rand.Source safe for concurrent use.
+type lockedSource struct {
+	sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.Lock()
+	defer s.Unlock()
+	s.src.Seed(seed)
+}
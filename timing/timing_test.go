@@ -0,0 +1,79 @@
+// Copyright 2014 The Azul3D Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timing
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"azul3d.org/engine/gfx"
+
+	"azul3d.org/examples/headless"
+)
+
+func TestFixed(t *testing.T) {
+	d := headless.New(image.Rect(0, 0, 1, 1), gfx.Precision{})
+	f := NewFixed(d, 16*time.Millisecond)
+	for frame := uint64(0); frame < 4; frame++ {
+		if got := f.FrameCount(); got != frame {
+			t.Errorf("frame count %d, want %d", got, frame)
+		}
+		if got, want := f.Time(), time.Duration(frame)*16*time.Millisecond; got != want {
+			t.Errorf("frame %d: time %v, want %v", frame, got, want)
+		}
+		if got := f.Dt(); got != 0.016 {
+			t.Errorf("frame %d: dt %v, want 0.016", frame, got)
+		}
+		d.Render()
+	}
+}
+
+func TestFix(t *testing.T) {
+	defer Fix(0)
+	d := headless.New(image.Rect(0, 0, 1, 1), gfx.Precision{})
+
+	Fix(10 * time.Millisecond)
+	f, ok := For(d).(*Fixed)
+	if !ok || f.Delta != 10*time.Millisecond {
+		t.Fatalf("For returned %#v after Fix, want a fixed clock", For(d))
+	}
+	Fix(0)
+	if c := For(d); c != Clock(d.Clock()) {
+		t.Errorf("For returned %#v after Fix(0), want the device's clock", c)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	draw := func() []int64 {
+		r := Rand()
+		var v []int64
+		for i := 0; i < 8; i++ {
+			v = append(v, r.Int63())
+		}
+		return v
+	}
+
+	// The same seed replays the same numbers.
+	Seed(42)
+	a := draw()
+	Seed(42)
+	b := draw()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("seed 42 drew %v, then %v", a, b)
+		}
+	}
+
+	Seed(43)
+	c := draw()
+	same := true
+	for i := range a {
+		same = same && a[i] == c[i]
+	}
+	if same {
+		t.Errorf("seeds 42 and 43 both drew %v", a)
+	}
+}